@@ -0,0 +1,92 @@
+package rimcu
+
+import (
+	"context"
+
+	"github.com/iwanbk/rimcu/result"
+)
+
+// Batch queues the Get, Setex, and Del operations to be executed at once.
+//
+// The Get of the keys which exist in the memory cache are answered from the memory cache,
+// the rest of the operations are sent to the server in one pipeline.
+// A Batch is not safe for concurrent use.
+type Batch struct {
+	sc  *StringsCache
+	ops []*result.BatchOp
+}
+
+// BatchResult is the result of a batch operation,
+// it is available after the Batch is executed
+type BatchResult struct {
+	op *result.BatchOp
+}
+
+// NewBatch creates a new empty Batch
+func (sc *StringsCache) NewBatch() *Batch {
+	return &Batch{
+		sc: sc,
+	}
+}
+
+// Get queues the get of the key, see StringsCache.Get
+func (b *Batch) Get(key string, expSecond int) *BatchResult {
+	return b.add(&result.BatchOp{
+		Type: result.BatchGet,
+		Keys: []string{b.sc.key(key)},
+		Exp:  expSecond,
+	})
+}
+
+// Setex queues the set of the key with the given expiration second, see StringsCache.Setex
+func (b *Batch) Setex(key string, val interface{}, exp int) *BatchResult {
+	return b.add(&result.BatchOp{
+		Type: result.BatchSetex,
+		Keys: []string{b.sc.key(key)},
+		Val:  val,
+		Exp:  exp,
+	})
+}
+
+// Del queues the deletion of the keys, see StringsCache.Del
+func (b *Batch) Del(keys ...string) *BatchResult {
+	return b.add(&result.BatchOp{
+		Type: result.BatchDel,
+		Keys: b.sc.keys(keys),
+	})
+}
+
+func (b *Batch) add(op *result.BatchOp) *BatchResult {
+	b.ops = append(b.ops, op)
+	return &BatchResult{op: op}
+}
+
+// Len returns the number of the queued operations
+func (b *Batch) Len() int {
+	return len(b.ops)
+}
+
+// Exec executes all of the queued operations and empties the batch.
+//
+// The returned error is the connection error, the error of each
+// operation is returned by it's BatchResult
+func (b *Batch) Exec(ctx context.Context) error {
+	ops := b.ops
+	b.ops = nil
+	if len(ops) == 0 {
+		return nil
+	}
+	return b.sc.engine.ExecBatch(ctx, ops)
+}
+
+// Result returns the result of the operation.
+//
+// The result is nil for the Setex and Del operation
+func (br *BatchResult) Result() (result.StringsResult, error) {
+	return br.op.Res, br.op.Err
+}
+
+// Err returns the error of the operation
+func (br *BatchResult) Err() error {
+	return br.op.Err
+}
@@ -0,0 +1,92 @@
+package rimcu
+
+import (
+	"bytes"
+	"encoding/gob"
+	"encoding/json"
+	"fmt"
+
+	"github.com/shamaton/msgpack"
+)
+
+// Codec encodes & decodes the value of the TypedCache
+type Codec interface {
+	// Marshal encodes the value to bytes
+	Marshal(v interface{}) ([]byte, error)
+
+	// Unmarshal decodes the bytes to the value pointed by v
+	Unmarshal(data []byte, v interface{}) error
+}
+
+var (
+	// JSONCodec encodes the value using encoding/json
+	JSONCodec Codec = jsonCodec{}
+
+	// MsgpackCodec encodes the value using msgpack
+	MsgpackCodec Codec = msgpackCodec{}
+
+	// GobCodec encodes the value using encoding/gob
+	GobCodec Codec = gobCodec{}
+
+	// RawCodec stores the []byte or string value as is
+	RawCodec Codec = rawCodec{}
+)
+
+type jsonCodec struct{}
+
+func (jsonCodec) Marshal(v interface{}) ([]byte, error) {
+	return json.Marshal(v)
+}
+
+func (jsonCodec) Unmarshal(data []byte, v interface{}) error {
+	return json.Unmarshal(data, v)
+}
+
+type msgpackCodec struct{}
+
+func (msgpackCodec) Marshal(v interface{}) ([]byte, error) {
+	return msgpack.Encode(v)
+}
+
+func (msgpackCodec) Unmarshal(data []byte, v interface{}) error {
+	return msgpack.Decode(data, v)
+}
+
+type gobCodec struct{}
+
+func (gobCodec) Marshal(v interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(v); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (gobCodec) Unmarshal(data []byte, v interface{}) error {
+	return gob.NewDecoder(bytes.NewReader(data)).Decode(v)
+}
+
+type rawCodec struct{}
+
+func (rawCodec) Marshal(v interface{}) ([]byte, error) {
+	switch val := v.(type) {
+	case []byte:
+		return val, nil
+	case string:
+		return []byte(val), nil
+	default:
+		return nil, fmt.Errorf("raw codec: unsupported type %T", v)
+	}
+}
+
+func (rawCodec) Unmarshal(data []byte, v interface{}) error {
+	switch ptr := v.(type) {
+	case *[]byte:
+		*ptr = append([]byte(nil), data...)
+	case *string:
+		*ptr = string(data)
+	default:
+		return fmt.Errorf("raw codec: unsupported type %T", v)
+	}
+	return nil
+}
@@ -0,0 +1,44 @@
+package rimcu
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+type codecTestVal struct {
+	Name string
+	Age  int
+}
+
+func TestCodec_RoundTrip(t *testing.T) {
+	codecs := map[string]Codec{
+		"json":    JSONCodec,
+		"msgpack": MsgpackCodec,
+		"gob":     GobCodec,
+	}
+	val := codecTestVal{Name: "rimcu", Age: 3}
+
+	for name, codec := range codecs {
+		t.Run(name, func(t *testing.T) {
+			data, err := codec.Marshal(val)
+			require.NoError(t, err)
+
+			var decoded codecTestVal
+			require.NoError(t, codec.Unmarshal(data, &decoded))
+			require.Equal(t, val, decoded)
+		})
+	}
+}
+
+func TestCodec_Raw(t *testing.T) {
+	data, err := RawCodec.Marshal("val_1")
+	require.NoError(t, err)
+
+	var decoded []byte
+	require.NoError(t, RawCodec.Unmarshal(data, &decoded))
+	require.Equal(t, []byte("val_1"), decoded)
+
+	_, err = RawCodec.Marshal(1)
+	require.Error(t, err)
+}
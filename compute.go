@@ -0,0 +1,16 @@
+package rimcu
+
+import (
+	"context"
+)
+
+// Compute returns the value computed by the fn, the value is cached in the memory cache
+// under the given name, and it is invalidated when one of the deps keys is invalidated.
+//
+// The fn is only called when the value is not in the memory cache,
+// the error of the fn is returned as is and the value is not cached.
+// The returned value is shared between the callers, it must not be modified.
+func (sc *StringsCache) Compute(ctx context.Context, name string, deps []string, exp int,
+	fn func(ctx context.Context) (interface{}, error)) (interface{}, error) {
+	return sc.engine.Compute(ctx, sc.key(name), sc.keys(deps), exp, fn)
+}
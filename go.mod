@@ -1,22 +1,29 @@
 module github.com/iwanbk/rimcu
 
-go 1.16
+go 1.18
 
 require (
-	github.com/alicebob/gopher-json v0.0.0-20180125190556-5a6b3ba71ee6 // indirect
 	github.com/alicebob/miniredis v2.5.0+incompatible
 	github.com/bluele/gcache v0.0.0-20190518031135-bc40bd653833
-	github.com/fatih/color v1.13.0 // indirect
-	github.com/gomodule/redigo v2.0.0+incompatible // indirect
 	github.com/iwanbk/resp3 v0.0.0-20200704064956-fff5b78e9612
 	github.com/karlseguin/ccache v2.0.3+incompatible
-	github.com/karlseguin/expect v1.0.7 // indirect
-	github.com/mattn/go-colorable v0.1.12 // indirect
-	github.com/rakyll/gotest v0.0.6 // indirect
 	github.com/rs/xid v1.2.1
 	github.com/shamaton/msgpack v1.1.1
 	github.com/smallnest/resp3 v0.1.1
 	github.com/stretchr/testify v1.5.1
+)
+
+require (
+	github.com/alicebob/gopher-json v0.0.0-20180125190556-5a6b3ba71ee6 // indirect
+	github.com/davecgh/go-spew v1.1.0 // indirect
+	github.com/emirpasic/gods v1.12.0 // indirect
+	github.com/fatih/color v1.13.0 // indirect
+	github.com/gomodule/redigo v2.0.0+incompatible // indirect
+	github.com/karlseguin/expect v1.0.7 // indirect
+	github.com/mattn/go-colorable v0.1.12 // indirect
+	github.com/pmezard/go-difflib v1.0.0 // indirect
+	github.com/rakyll/gotest v0.0.6 // indirect
 	github.com/yuin/gopher-lua v0.0.0-20191220021717-ab39c6098bdb // indirect
 	golang.org/x/sys v0.0.0-20220422013727-9388b58f7150 // indirect
+	gopkg.in/yaml.v2 v2.2.2 // indirect
 )
@@ -1,11 +1,14 @@
 package cluster
 
 import (
+	"errors"
 	"fmt"
-	"log"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/iwanbk/rimcu/internal/redigo/redis"
+	"github.com/iwanbk/rimcu/logger"
 )
 
 const (
@@ -13,6 +16,11 @@ const (
 	roleSlave  = "slave"
 )
 
+var (
+	// ErrNoSeeds returned when there is no node to discover the cluster from
+	ErrNoSeeds = errors.New("no cluster seeds")
+)
+
 // ClusterInfo stores all things related to the cluster
 type ClusterInfo struct {
 	Shards map[string]Shard
@@ -31,15 +39,53 @@ func (ci ClusterInfo) Masters() []string {
 func (ci ClusterInfo) Slaves() []string {
 	slaves := make([]string, 0, len(ci.Shards))
 	for _, shard := range ci.Shards {
-		slaves = append(slaves, shard.Slaves[0].Addr)
+		for _, slave := range shard.Slaves {
+			slaves = append(slaves, slave.Addr)
+		}
 	}
 	return slaves
 }
 
+// Addrs returns address of all the cluster nodes
+func (ci ClusterInfo) Addrs() []string {
+	return append(ci.Masters(), ci.Slaves()...)
+}
+
+// MasterOf returns address of the master which serves the given slot
+func (ci ClusterInfo) MasterOf(slot uint16) (string, bool) {
+	for _, shard := range ci.Shards {
+		if shard.HasSlot(slot) {
+			return shard.Master.Addr, true
+		}
+	}
+	return "", false
+}
+
+// SlotsOf returns slot ranges served by the master with the given address
+func (ci ClusterInfo) SlotsOf(addr string) ([]SlotRange, bool) {
+	for _, shard := range ci.Shards {
+		if shard.Master.Addr == addr {
+			return shard.Slots, true
+		}
+	}
+	return nil, false
+}
+
 // Shard represent a redis cluster shard
 type Shard struct {
 	Master Node
 	Slaves []Node
+	Slots  []SlotRange
+}
+
+// HasSlot returns true if the given slot is served by this shard
+func (s Shard) HasSlot(slot uint16) bool {
+	for _, sr := range s.Slots {
+		if sr.Contains(slot) {
+			return true
+		}
+	}
+	return false
 }
 
 // Node represents a redis cluster node, could be master or slave
@@ -54,46 +100,89 @@ type Node struct {
 type Explorer struct {
 	// cluster seeds
 	seeds []string
-	pools []*redis.Pool
+	opts  []redis.DialOption
+
+	mtx sync.Mutex
+	// nodes found on the last discovery,
+	// used as fallback when all of the seeds are not reachable
+	knownAddrs []string
+
+	logger logger.Logger
 }
 
 // NewExplorer creates new explorer object
-func NewExplorer(seeds []string, password string) *Explorer {
-	var (
-		pools []*redis.Pool
-		opts  []redis.DialOption
-	)
+func NewExplorer(seeds []string, password string, logger logger.Logger) *Explorer {
+	opts := []redis.DialOption{
+		redis.DialConnectTimeout(5 * time.Second),
+		redis.DialReadTimeout(5 * time.Second),
+		redis.DialWriteTimeout(5 * time.Second),
+	}
 	if password != "" {
 		opts = append(opts, redis.DialPassword(password))
 	}
 
-	for _, seed := range seeds {
-		pool := &redis.Pool{
+	return &Explorer{
+		seeds:  seeds,
+		opts:   opts,
+		logger: logger,
+	}
+}
+
+// Discover the cluster topology.
+//
+// It tries the seeds in order, and then the nodes found by the previous discovery,
+// until one of them succeed.
+func (ex *Explorer) Discover() (ClusterInfo, error) {
+	ex.mtx.Lock()
+	addrs := append(append([]string{}, ex.seeds...), ex.knownAddrs...)
+	ex.mtx.Unlock()
 
-			Dial: func() (redis.Conn, error) {
-				return redis.Dial("tcp", seed, opts...)
-			},
+	lastErr := ErrNoSeeds
+	for _, addr := range addrs {
+		ci, err := ex.discover(addr)
+		if err != nil {
+			ex.logger.Errorf("failed to discover cluster from %v: %v", addr, err)
+			lastErr = err
+			continue
 		}
-		pools = append(pools, pool)
-	}
 
-	return &Explorer{
-		seeds: seeds,
-		pools: pools,
+		ex.mtx.Lock()
+		ex.knownAddrs = ci.Addrs()
+		ex.mtx.Unlock()
+
+		return ci, nil
 	}
+	return ClusterInfo{}, lastErr
 }
 
-// Discover the cluster topology
-func (ex *Explorer) Discover() (ClusterInfo, error) {
-	pool := ex.pools[0]
-	conn := pool.Get()
+// discover the cluster topology from the given node.
+//
+// It uses `CLUSTER SHARDS` which available since Redis 7,
+// and fallback to `CLUSTER NODES` for the older version
+func (ex *Explorer) discover(addr string) (ClusterInfo, error) {
+	conn, err := redis.Dial("tcp", addr, ex.opts...)
+	if err != nil {
+		return ClusterInfo{}, err
+	}
 	defer conn.Close()
 
-	str, err := redis.String(conn.Do("cluster", "nodes"))
+	shardsReply, err := redis.Values(conn.Do("CLUSTER", "SHARDS"))
+	if err == nil {
+		return parseClusterShards(shardsReply)
+	}
+	ex.logger.Debugf("CLUSTER SHARDS failed, fallback to CLUSTER NODES: %v", err)
+
+	str, err := redis.String(conn.Do("CLUSTER", "NODES"))
 	if err != nil {
 		return ClusterInfo{}, err
 	}
+	return parseClusterNodes(str, ex.logger)
+}
 
+// parseClusterNodes parses reply of the `CLUSTER NODES` command.
+//
+// The slaves which master is not found, e.g. the master is failed, are skipped
+func parseClusterNodes(str string, logger logger.Logger) (ClusterInfo, error) {
 	var (
 		nodes  = map[string]Node{}
 		shards = map[string]Shard{}
@@ -101,26 +190,28 @@ func (ex *Explorer) Discover() (ClusterInfo, error) {
 
 	lines := strings.Split(str, "\n")
 	for _, line := range lines {
-		if !strings.Contains(line, "connected") {
+		words := strings.Fields(line)
+		if len(words) < 8 || words[7] != "connected" {
 			continue
 		}
-		words := strings.Split(line, " ")
-		if len(words) < 5 {
+		role := getRole(words[2])
+		if role == "" {
+			// failed or handshaking node
 			continue
 		}
 		node := Node{
 			ID:   words[0],
-			Addr: ex.getAddr(words[1]),
-			Role: ex.getRole(words[2]),
+			Addr: getAddr(words[1]),
+			Role: role,
 		}
 		if node.Role == roleSlave {
 			node.MasterID = words[3]
 		}
-		log.Printf("node=\n%+v", node)
 		nodes[node.ID] = node
 		if node.Role == roleMaster {
 			shards[node.ID] = Shard{
 				Master: node,
+				Slots:  getSlots(words),
 			}
 		}
 	}
@@ -132,7 +223,10 @@ func (ex *Explorer) Discover() (ClusterInfo, error) {
 		}
 		shard, ok := shards[node.MasterID]
 		if !ok {
-			return ClusterInfo{}, fmt.Errorf("shard not found for slave with master ID:%v", node.MasterID)
+			// the failover is in progress, the slave will be promoted or
+			// assigned to the new master in the next refresh
+			logger.Errorf("skipping slave %v, shard not found for master ID:%v", node.Addr, node.MasterID)
+			continue
 		}
 		shard.Slaves = append(shard.Slaves, node)
 		shards[node.MasterID] = shard
@@ -143,15 +237,152 @@ func (ex *Explorer) Discover() (ClusterInfo, error) {
 	}, nil
 }
 
-func (ex *Explorer) getRole(role string) string {
-	words := strings.Split(role, ",")
-	if len(words) == 1 {
-		return words[0]
+// parseClusterShards parses reply of the `CLUSTER SHARDS` command.
+//
+// Each shard is a map with `slots` and `nodes` fields, and each node
+// is a map of the node properties.
+func parseClusterShards(reply []interface{}) (ClusterInfo, error) {
+	shards := map[string]Shard{}
+
+	for _, shardReply := range reply {
+		fields, err := redis.Values(shardReply, nil)
+		if err != nil {
+			return ClusterInfo{}, err
+		}
+
+		var (
+			shard  Shard
+			slaves []Node
+		)
+		for i := 0; i+1 < len(fields); i += 2 {
+			name, err := redis.String(fields[i], nil)
+			if err != nil {
+				return ClusterInfo{}, err
+			}
+			switch name {
+			case "slots":
+				ints, err := redis.Int64s(fields[i+1], nil)
+				if err != nil {
+					return ClusterInfo{}, err
+				}
+				for j := 0; j+1 < len(ints); j += 2 {
+					shard.Slots = append(shard.Slots, SlotRange{
+						Start: uint16(ints[j]),
+						End:   uint16(ints[j+1]),
+					})
+				}
+			case "nodes":
+				nodeReplies, err := redis.Values(fields[i+1], nil)
+				if err != nil {
+					return ClusterInfo{}, err
+				}
+				for _, nodeReply := range nodeReplies {
+					node, online, err := parseShardNode(nodeReply)
+					if err != nil {
+						return ClusterInfo{}, err
+					}
+					if !online {
+						continue
+					}
+					if node.Role == roleMaster {
+						shard.Master = node
+					} else {
+						slaves = append(slaves, node)
+					}
+				}
+			}
+		}
+
+		if shard.Master.ID == "" {
+			// shard without online master
+			continue
+		}
+		for _, slave := range slaves {
+			slave.MasterID = shard.Master.ID
+			shard.Slaves = append(shard.Slaves, slave)
+		}
+		shards[shard.Master.ID] = shard
+	}
+
+	return ClusterInfo{
+		Shards: shards,
+	}, nil
+}
+
+// parseShardNode parses node of the `CLUSTER SHARDS` reply.
+//
+// It also returns the node health status
+func parseShardNode(reply interface{}) (Node, bool, error) {
+	fields, err := redis.Values(reply, nil)
+	if err != nil {
+		return Node{}, false, err
+	}
+
+	var (
+		node       Node
+		ip, health string
+		port       int64
+	)
+	for i := 0; i+1 < len(fields); i += 2 {
+		name, err := redis.String(fields[i], nil)
+		if err != nil {
+			return Node{}, false, err
+		}
+		switch name {
+		case "id":
+			node.ID, err = redis.String(fields[i+1], nil)
+		case "ip":
+			ip, err = redis.String(fields[i+1], nil)
+		case "port":
+			port, err = redis.Int64(fields[i+1], nil)
+		case "role":
+			node.Role, err = redis.String(fields[i+1], nil)
+		case "health":
+			health, err = redis.String(fields[i+1], nil)
+		}
+		if err != nil {
+			return Node{}, false, fmt.Errorf("invalid node field %v: %v", name, err)
+		}
+	}
+	if node.Role != roleMaster {
+		node.Role = roleSlave
+	}
+	node.Addr = fmt.Sprintf("%s:%d", ip, port)
+
+	return node, health == "online", nil
+}
+
+// getRole returns role of the node from the `CLUSTER NODES` flags.
+//
+// It returns empty string for node which is failed or in handshake.
+func getRole(flags string) string {
+	var role string
+	for _, flag := range strings.Split(flags, ",") {
+		switch flag {
+		case roleMaster, roleSlave:
+			role = flag
+		case "fail", "handshake", "noaddr":
+			return ""
+		}
+	}
+	return role
+}
+
+// getSlots parses the slot ranges of the `CLUSTER NODES` line,
+// which started at the 9th word
+func getSlots(words []string) []SlotRange {
+	var slots []SlotRange
+	for i := 8; i < len(words); i++ {
+		sr, ok := parseSlotRange(words[i])
+		if !ok {
+			continue
+		}
+		slots = append(slots, sr)
 	}
-	return words[1]
+	return slots
 }
 
-func (ex *Explorer) getAddr(line string) string {
+func getAddr(line string) string {
 	elems := strings.Split(line, "@")
 	return elems[0]
 }
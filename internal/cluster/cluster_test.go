@@ -0,0 +1,100 @@
+package cluster
+
+import (
+	"testing"
+
+	"github.com/iwanbk/rimcu/logger"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseClusterNodes(t *testing.T) {
+	str := `07c37dfeb235213a872192d90877d0cd55635b91 127.0.0.1:30004@31004 slave e7d1eecce10fd6bb5eb35b9f99a514335d9ba9ca 0 1426238317239 4 connected
+67ed2db8d677e59ec4a4cefb06858cf2a1a89fa1 127.0.0.1:30002@31002 master - 0 1426238316232 2 connected 5461-10922
+292f8b365bb7edb5e285caf0b7e6ddc7265d2f4f 127.0.0.1:30003@31003 master - 0 1426238318243 3 connected 10923-16383
+6ec23923021cf3ffec47632106199cb7f496ce01 127.0.0.1:30005@31005 slave,fail 67ed2db8d677e59ec4a4cefb06858cf2a1a89fa1 0 1426238316232 5 connected
+e7d1eecce10fd6bb5eb35b9f99a514335d9ba9ca 127.0.0.1:30001@31001 myself,master - 0 0 1 connected 0-5460 [5461->-67ed2db8d677e59ec4a4cefb06858cf2a1a89fa1]
+`
+	ci, err := parseClusterNodes(str, logger.NewDefault())
+	require.NoError(t, err)
+	require.Len(t, ci.Shards, 3)
+
+	shard := ci.Shards["e7d1eecce10fd6bb5eb35b9f99a514335d9ba9ca"]
+	require.Equal(t, "127.0.0.1:30001", shard.Master.Addr)
+	require.Equal(t, []SlotRange{{Start: 0, End: 5460}}, shard.Slots)
+	require.Len(t, shard.Slaves, 1)
+	require.Equal(t, "127.0.0.1:30004", shard.Slaves[0].Addr)
+
+	// failed slave is not included
+	require.Empty(t, ci.Shards["67ed2db8d677e59ec4a4cefb06858cf2a1a89fa1"].Slaves)
+
+	addr, ok := ci.MasterOf(Slot("foo"))
+	require.True(t, ok)
+	require.Equal(t, "127.0.0.1:30003", addr)
+
+	// master without slaves must not panic
+	require.Equal(t, []string{"127.0.0.1:30004"}, ci.Slaves())
+}
+
+// slave of the failed master must be skipped, not failing the whole parse
+func TestParseClusterNodes_FailedMaster(t *testing.T) {
+	str := `07c37dfeb235213a872192d90877d0cd55635b91 127.0.0.1:30004@31004 slave e7d1eecce10fd6bb5eb35b9f99a514335d9ba9ca 0 1426238317239 4 connected
+67ed2db8d677e59ec4a4cefb06858cf2a1a89fa1 127.0.0.1:30002@31002 myself,master - 0 1426238316232 2 connected 5461-10922
+292f8b365bb7edb5e285caf0b7e6ddc7265d2f4f 127.0.0.1:30003@31003 master - 0 1426238318243 3 connected 10923-16383
+e7d1eecce10fd6bb5eb35b9f99a514335d9ba9ca 127.0.0.1:30001@31001 master,fail - 0 0 1 connected 0-5460
+`
+	ci, err := parseClusterNodes(str, logger.NewDefault())
+	require.NoError(t, err)
+	require.Len(t, ci.Shards, 2)
+	require.NotContains(t, ci.Shards, "e7d1eecce10fd6bb5eb35b9f99a514335d9ba9ca")
+	require.Empty(t, ci.Slaves())
+
+	_, ok := ci.MasterOf(Slot("foo"))
+	require.True(t, ok)
+}
+
+func TestParseClusterShards(t *testing.T) {
+	reply := []interface{}{
+		[]interface{}{
+			[]byte("slots"), []interface{}{int64(0), int64(5460), int64(10923), int64(10923)},
+			[]byte("nodes"), []interface{}{
+				[]interface{}{
+					[]byte("id"), []byte("e10b7051d6bf2d5febd39a2be297bbaea6084111"),
+					[]byte("port"), int64(30001),
+					[]byte("ip"), []byte("127.0.0.1"),
+					[]byte("role"), []byte("master"),
+					[]byte("health"), []byte("online"),
+				},
+				[]interface{}{
+					[]byte("id"), []byte("1901f5962d865341e81c85f9f596b1e7160c35ce"),
+					[]byte("port"), int64(30006),
+					[]byte("ip"), []byte("127.0.0.1"),
+					[]byte("role"), []byte("replica"),
+					[]byte("health"), []byte("online"),
+				},
+			},
+		},
+		[]interface{}{
+			[]byte("slots"), []interface{}{int64(5461), int64(10922)},
+			[]byte("nodes"), []interface{}{
+				[]interface{}{
+					[]byte("id"), []byte("a9a2ff5ac48fa07b4e9b55d44a9d8b8e5e1d5c41"),
+					[]byte("port"), int64(30002),
+					[]byte("ip"), []byte("127.0.0.1"),
+					[]byte("role"), []byte("master"),
+					[]byte("health"), []byte("fail"),
+				},
+			},
+		},
+	}
+
+	ci, err := parseClusterShards(reply)
+	require.NoError(t, err)
+	require.Len(t, ci.Shards, 1)
+
+	shard := ci.Shards["e10b7051d6bf2d5febd39a2be297bbaea6084111"]
+	require.Equal(t, "127.0.0.1:30001", shard.Master.Addr)
+	require.Equal(t, []SlotRange{{Start: 0, End: 5460}, {Start: 10923, End: 10923}}, shard.Slots)
+	require.Len(t, shard.Slaves, 1)
+	require.Equal(t, "127.0.0.1:30006", shard.Slaves[0].Addr)
+	require.Equal(t, shard.Master.ID, shard.Slaves[0].MasterID)
+}
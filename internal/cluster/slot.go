@@ -0,0 +1,88 @@
+package cluster
+
+import (
+	"strconv"
+	"strings"
+)
+
+// NumSlots is number of hash slots in redis cluster
+const NumSlots = 16384
+
+// SlotRange is an inclusive range of cluster hash slots
+type SlotRange struct {
+	Start uint16
+	End   uint16
+}
+
+// Contains returns true if the given slot is inside the range
+func (sr SlotRange) Contains(slot uint16) bool {
+	return slot >= sr.Start && slot <= sr.End
+}
+
+// Slot returns the hash slot of the given key.
+//
+// It follows the redis cluster spec, only the hash tag is hashed
+// if the key contains it.
+func Slot(key string) uint16 {
+	if start := strings.IndexByte(key, '{'); start >= 0 {
+		if end := strings.IndexByte(key[start+1:], '}'); end > 0 {
+			key = key[start+1 : start+1+end]
+		}
+	}
+	return crc16(key) % NumSlots
+}
+
+// parseSlotRange parses slot range in the `CLUSTER NODES` format:
+// - single slot: `1000`
+// - range of slots: `0-5460`
+//
+// Importing & migrating slots like `[1000->-id]` are not parsed
+func parseSlotRange(str string) (SlotRange, bool) {
+	if strings.HasPrefix(str, "[") {
+		return SlotRange{}, false
+	}
+
+	elems := strings.SplitN(str, "-", 2)
+	start, err := strconv.ParseUint(elems[0], 10, 16)
+	if err != nil {
+		return SlotRange{}, false
+	}
+	if len(elems) == 1 {
+		return SlotRange{Start: uint16(start), End: uint16(start)}, true
+	}
+
+	end, err := strconv.ParseUint(elems[1], 10, 16)
+	if err != nil || end < start {
+		return SlotRange{}, false
+	}
+	return SlotRange{Start: uint16(start), End: uint16(end)}, true
+}
+
+// crc16 implements the CRC16-CCITT (XMODEM) used by redis cluster
+func crc16(str string) uint16 {
+	var crc uint16
+	for i := 0; i < len(str); i++ {
+		crc ^= uint16(str[i]) << 8
+		for j := 0; j < 8; j++ {
+			if crc&0x8000 != 0 {
+				crc = crc<<1 ^ 0x1021
+			} else {
+				crc <<= 1
+			}
+		}
+	}
+	return crc
+}
+
+// SameSlots returns true if both of the slot range lists are equal
+func SameSlots(a, b []SlotRange) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,41 @@
+package cluster
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSlot(t *testing.T) {
+	// test vector of the redis cluster spec
+	require.Equal(t, uint16(0x31C3), crc16("123456789"))
+
+	require.Equal(t, uint16(12182), Slot("foo"))
+	require.Equal(t, uint16(5061), Slot("bar"))
+
+	// hash tags
+	require.Equal(t, Slot("user1000"), Slot("{user1000}.following"))
+	require.Equal(t, Slot("{user1000}.followers"), Slot("{user1000}.following"))
+
+	// empty hash tag hashes the whole key
+	require.Equal(t, crc16("foo{}{bar}")%NumSlots, Slot("foo{}{bar}"))
+}
+
+func TestParseSlotRange(t *testing.T) {
+	testCases := []struct {
+		str      string
+		expected SlotRange
+		ok       bool
+	}{
+		{"0-5460", SlotRange{Start: 0, End: 5460}, true},
+		{"1000", SlotRange{Start: 1000, End: 1000}, true},
+		{"[1000->-e7d1eecce10fd6bb5eb35b9f99a514335d9ba9ca]", SlotRange{}, false},
+		{"5460-0", SlotRange{}, false},
+		{"abc", SlotRange{}, false},
+	}
+	for _, tc := range testCases {
+		sr, ok := parseSlotRange(tc.str)
+		require.Equal(t, tc.ok, ok, tc.str)
+		require.Equal(t, tc.expected, sr, tc.str)
+	}
+}
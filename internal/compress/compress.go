@@ -0,0 +1,109 @@
+// Package compress implements the value compression which shared
+// by all of the rimcu clients.
+//
+// The compressed value is prefixed by a header, so the value could be
+// decompressed by any rimcu client, regardless of it's compression config.
+package compress
+
+import (
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"io"
+	"io/ioutil"
+)
+
+// Header of the compressed value:
+// - magic bytes `\x00RZ`
+// - algorithm byte, currently only gzip (0x01) is supported
+const Header = "\x00RZ\x01"
+
+// DefaultMaxSize is the default maximum size of the decompressed value
+const DefaultMaxSize = 64 << 20
+
+// ErrTooLarge returned when the decompressed value exceeds the maximum size
+var ErrTooLarge = errors.New("decompressed value is too large")
+
+// Compressor compresses the values which size is at least the threshold
+type Compressor struct {
+	threshold int
+}
+
+// New creates new compressor with the given threshold.
+//
+// It returns nil if the threshold is not positive, which means
+// the compression is disabled
+func New(threshold int) *Compressor {
+	if threshold <= 0 {
+		return nil
+	}
+	return &Compressor{
+		threshold: threshold,
+	}
+}
+
+// Compress the given value if it is a string or []byte which is large enough,
+// otherwise the value is returned as is.
+func (c *Compressor) Compress(val interface{}) (interface{}, error) {
+	if c == nil {
+		return val, nil
+	}
+
+	var data []byte
+	switch v := val.(type) {
+	case []byte:
+		data = v
+	case string:
+		data = []byte(v)
+	default:
+		return val, nil
+	}
+	if len(data) < c.threshold {
+		return val, nil
+	}
+
+	var buf bytes.Buffer
+	buf.WriteString(Header)
+	zw := gzip.NewWriter(&buf)
+	if _, err := zw.Write(data); err != nil {
+		return nil, err
+	}
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// IsCompressed returns true if the data has the compression header
+func IsCompressed(data []byte) bool {
+	return len(data) >= len(Header) && string(data[:len(Header)]) == Header
+}
+
+// Decompress the data if it has the compression header,
+// otherwise the data is returned as is.
+//
+// The data is read from the shared server, so it returns ErrTooLarge instead of
+// decompressing more than maxSize bytes, DefaultMaxSize is used if maxSize is not positive.
+func Decompress(data []byte, maxSize int) ([]byte, error) {
+	if !IsCompressed(data) {
+		return data, nil
+	}
+
+	zr, err := gzip.NewReader(bytes.NewReader(data[len(Header):]))
+	if err != nil {
+		return nil, err
+	}
+	defer zr.Close()
+
+	if maxSize <= 0 {
+		maxSize = DefaultMaxSize
+	}
+	data, err = ioutil.ReadAll(io.LimitReader(zr, int64(maxSize)+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(data) > maxSize {
+		return nil, ErrTooLarge
+	}
+	return data, nil
+}
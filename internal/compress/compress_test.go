@@ -0,0 +1,60 @@
+package compress
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestCompress_Threshold(t *testing.T) {
+	c := New(100)
+
+	// small value is not compressed
+	small, err := c.Compress("val_1")
+	require.NoError(t, err)
+	require.Equal(t, "val_1", small)
+
+	// large value is compressed
+	val := strings.Repeat("<div>rimcu</div>", 100)
+	compressed, err := c.Compress(val)
+	require.NoError(t, err)
+	data := compressed.([]byte)
+	require.True(t, IsCompressed(data))
+	require.Less(t, len(data), len(val))
+
+	decompressed, err := Decompress(data, 0)
+	require.NoError(t, err)
+	require.Equal(t, val, string(decompressed))
+
+	// uncompressed data is returned as is
+	raw, err := Decompress([]byte("val_1"), 0)
+	require.NoError(t, err)
+	require.Equal(t, []byte("val_1"), raw)
+}
+
+func TestCompress_Disabled(t *testing.T) {
+	c := New(0)
+	require.Nil(t, c)
+
+	val := strings.Repeat("a", 1000)
+	res, err := c.Compress(val)
+	require.NoError(t, err)
+	require.Equal(t, val, res)
+}
+
+func TestCompress_MaxSize(t *testing.T) {
+	c := New(100)
+
+	val := strings.Repeat("a", 1000)
+	compressed, err := c.Compress(val)
+	require.NoError(t, err)
+	data := compressed.([]byte)
+
+	_, err = Decompress(data, 999)
+	require.Equal(t, ErrTooLarge, err)
+
+	decompressed, err := Decompress(data, 1000)
+	require.NoError(t, err)
+	require.Equal(t, val, string(decompressed))
+}
@@ -0,0 +1,140 @@
+// Package encrypt implements the value encryption which shared
+// by all of the rimcu clients.
+//
+// The encrypted value is prefixed by a header and the ID of the key
+// used to encrypt it, so the value could be decrypted during the key rotation
+// as long as the key is still configured.
+package encrypt
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"errors"
+	"fmt"
+
+	"github.com/iwanbk/rimcu/internal/redisarg"
+)
+
+// Header of the encrypted value:
+// - magic bytes `\x00RE`
+// - algorithm byte, currently only AES-GCM (0x01) is supported
+//
+// It is followed by the key ID length byte, the key ID, the nonce, and the ciphertext
+const Header = "\x00RE\x01"
+
+var (
+	// ErrUnknownKey returned when the value was encrypted using key which is not configured
+	ErrUnknownKey = errors.New("unknown encryption key")
+
+	// ErrInvalidValue returned when the encrypted value is malformed
+	ErrInvalidValue = errors.New("invalid encrypted value")
+)
+
+// Encryptor encrypts & decrypts the values using AES-GCM
+type Encryptor struct {
+	activeID string
+	aeads    map[string]cipher.AEAD
+}
+
+// New creates new encryptor with the given keys.
+//
+// The keys are map of key ID to the AES key (16, 24, or 32 bytes),
+// the values are encrypted using the key with the activeID,
+// and decrypted using the key with the ID found in the value.
+// It returns nil if there is no key, which means the encryption is disabled
+func New(keys map[string][]byte, activeID string) (*Encryptor, error) {
+	if len(keys) == 0 {
+		return nil, nil
+	}
+	if _, ok := keys[activeID]; !ok {
+		return nil, fmt.Errorf("active encryption key %q is not configured", activeID)
+	}
+
+	aeads := make(map[string]cipher.AEAD, len(keys))
+	for id, key := range keys {
+		if len(id) == 0 || len(id) > 255 {
+			return nil, fmt.Errorf("invalid encryption key ID %q", id)
+		}
+		block, err := aes.NewCipher(key)
+		if err != nil {
+			return nil, fmt.Errorf("invalid encryption key %q: %w", id, err)
+		}
+		aead, err := cipher.NewGCM(block)
+		if err != nil {
+			return nil, err
+		}
+		aeads[id] = aead
+	}
+	return &Encryptor{
+		activeID: activeID,
+		aeads:    aeads,
+	}, nil
+}
+
+// Encrypt the given value using the active key.
+//
+// The non string values are formatted the same way as the redis command arguments
+func (e *Encryptor) Encrypt(val interface{}) (interface{}, error) {
+	if e == nil {
+		return val, nil
+	}
+
+	data := redisarg.Format(val)
+
+	aead := e.aeads[e.activeID]
+	prefix := make([]byte, 0, len(Header)+1+len(e.activeID)+aead.NonceSize())
+	prefix = append(prefix, Header...)
+	prefix = append(prefix, byte(len(e.activeID)))
+	prefix = append(prefix, e.activeID...)
+
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+
+	// the header & key ID are authenticated as additional data
+	out := append(prefix, nonce...)
+	return aead.Seal(out, nonce, data, prefix), nil
+}
+
+// IsEncrypted returns true if the data has the encryption header
+func IsEncrypted(data []byte) bool {
+	return len(data) >= len(Header) && string(data[:len(Header)]) == Header
+}
+
+// Decrypt the data if it has the encryption header,
+// otherwise the data is returned as is.
+//
+// The plain data is accepted to read the values written before the encryption is enabled,
+// and the values written by the commands which modify the value on the server, e.g. INCR.
+// So the encryption protects the confidentiality of the values, it doesn't protect
+// against the one who could write plain values to the server.
+func (e *Encryptor) Decrypt(data []byte) ([]byte, error) {
+	if !IsEncrypted(data) {
+		return data, nil
+	}
+	if e == nil {
+		return nil, ErrUnknownKey
+	}
+
+	rest := data[len(Header):]
+	if len(rest) < 1 || len(rest) < 1+int(rest[0]) {
+		return nil, ErrInvalidValue
+	}
+	idLen := int(rest[0])
+	id := string(rest[1 : 1+idLen])
+	prefix := data[:len(Header)+1+idLen]
+	rest = rest[1+idLen:]
+
+	aead, ok := e.aeads[id]
+	if !ok {
+		return nil, fmt.Errorf("%w: %q", ErrUnknownKey, id)
+	}
+	if len(rest) < aead.NonceSize() {
+		return nil, ErrInvalidValue
+	}
+	nonce, ciphertext := rest[:aead.NonceSize()], rest[aead.NonceSize():]
+
+	return aead.Open(nil, nonce, ciphertext, prefix)
+}
@@ -0,0 +1,94 @@
+package encrypt
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+var (
+	testKey1 = bytes.Repeat([]byte{1}, 32)
+	testKey2 = bytes.Repeat([]byte{2}, 32)
+)
+
+func TestEncryptor_Rotation(t *testing.T) {
+	oldEnc, err := New(map[string][]byte{"k1": testKey1}, "k1")
+	require.NoError(t, err)
+
+	encrypted, err := oldEnc.Encrypt("secret")
+	require.NoError(t, err)
+	data := encrypted.([]byte)
+	require.True(t, IsEncrypted(data))
+	require.False(t, bytes.Contains(data, []byte("secret")))
+
+	// the rotated encryptor must be able to decrypt the value encrypted by the old key
+	newEnc, err := New(map[string][]byte{"k1": testKey1, "k2": testKey2}, "k2")
+	require.NoError(t, err)
+
+	decrypted, err := newEnc.Decrypt(data)
+	require.NoError(t, err)
+	require.Equal(t, []byte("secret"), decrypted)
+
+	// but the old encryptor doesn't know the new key
+	encrypted, err = newEnc.Encrypt([]byte("secret"))
+	require.NoError(t, err)
+	_, err = oldEnc.Decrypt(encrypted.([]byte))
+	require.True(t, errors.Is(err, ErrUnknownKey))
+}
+
+func TestEncryptor_Tampered(t *testing.T) {
+	enc, err := New(map[string][]byte{"k1": testKey1}, "k1")
+	require.NoError(t, err)
+
+	encrypted, err := enc.Encrypt("secret")
+	require.NoError(t, err)
+	data := encrypted.([]byte)
+	data[len(data)-1] ^= 0xff
+
+	_, err = enc.Decrypt(data)
+	require.Error(t, err)
+}
+
+func TestEncryptor_Disabled(t *testing.T) {
+	enc, err := New(nil, "")
+	require.NoError(t, err)
+	require.Nil(t, enc)
+
+	val, err := enc.Encrypt("plain")
+	require.NoError(t, err)
+	require.Equal(t, "plain", val)
+
+	data, err := enc.Decrypt([]byte("plain"))
+	require.NoError(t, err)
+	require.Equal(t, []byte("plain"), data)
+
+	_, err = New(map[string][]byte{"k1": []byte("short")}, "k1")
+	require.Error(t, err)
+}
+
+// the non string values are encrypted as they would be sent to the server
+func TestEncryptor_Scalar(t *testing.T) {
+	enc, err := New(map[string][]byte{"k1": testKey1}, "k1")
+	require.NoError(t, err)
+
+	tests := []struct {
+		val  interface{}
+		want string
+	}{
+		{val: 42, want: "42"},
+		{val: int64(-7), want: "-7"},
+		{val: 1.5, want: "1.5"},
+		{val: true, want: "1"},
+		{val: uint8(3), want: "3"},
+	}
+	for _, tc := range tests {
+		encrypted, err := enc.Encrypt(tc.val)
+		require.NoError(t, err)
+
+		decrypted, err := enc.Decrypt(encrypted.([]byte))
+		require.NoError(t, err)
+		require.Equal(t, tc.want, string(decrypted))
+	}
+}
@@ -0,0 +1,33 @@
+// Package invalidation contains the listeners of the in memory cache invalidation
+package invalidation
+
+import (
+	"sync"
+
+	"github.com/iwanbk/rimcu/result"
+)
+
+// Listeners is the list of the invalidation listeners,
+// the zero value is ready to use
+type Listeners struct {
+	mtx sync.RWMutex
+	fns []result.InvalidateFunc
+}
+
+// Add adds the listener
+func (l *Listeners) Add(fn result.InvalidateFunc) {
+	l.mtx.Lock()
+	defer l.mtx.Unlock()
+
+	l.fns = append(l.fns, fn)
+}
+
+// Notify calls all of the listeners with the given key and reason
+func (l *Listeners) Notify(key string, reason result.InvalidationReason) {
+	l.mtx.RLock()
+	defer l.mtx.RUnlock()
+
+	for _, fn := range l.fns {
+		fn(key, reason)
+	}
+}
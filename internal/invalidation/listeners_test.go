@@ -0,0 +1,28 @@
+package invalidation
+
+import (
+	"testing"
+
+	"github.com/iwanbk/rimcu/result"
+	"github.com/stretchr/testify/require"
+)
+
+func TestListeners_Notify(t *testing.T) {
+	var (
+		l     Listeners
+		calls []string
+	)
+
+	// no listener
+	l.Notify("key_1", result.InvalidationServer)
+
+	l.Add(func(key string, reason result.InvalidationReason) {
+		calls = append(calls, "1:"+key+":"+reason.String())
+	})
+	l.Add(func(key string, reason result.InvalidationReason) {
+		calls = append(calls, "2:"+key+":"+reason.String())
+	})
+
+	l.Notify("key_2", result.InvalidationEviction)
+	require.Equal(t, []string{"1:key_2:eviction", "2:key_2:eviction"}, calls)
+}
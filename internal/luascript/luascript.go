@@ -0,0 +1,16 @@
+// Package luascript contains the lua scripts used by rimcu
+package luascript
+
+// CompareAndSwap sets the key to the new value with the expiration
+// only if the current value equals to the old value.
+//
+// KEYS[1]: key, ARGV[1]: old value, ARGV[2]: new value, ARGV[3]: expiration in second.
+// It returns 1 if the value was swapped, 0 otherwise.
+const CompareAndSwap = `
+local cur = redis.call('GET', KEYS[1])
+if cur ~= ARGV[1] then
+	return 0
+end
+redis.call('SET', KEYS[1], ARGV[2], 'EX', ARGV[3])
+return 1
+`
@@ -0,0 +1,106 @@
+// Package pending tracks the keys being read from the server
+package pending
+
+import "sync"
+
+// Reads tracks the keys being read from the server,
+// to detect the invalidation message which arrives before
+// the value is put in the in memory cache.
+type Reads struct {
+	mtx sync.Mutex
+	m   map[string]*read
+}
+
+type read struct {
+	refs        int
+	invalidated bool
+}
+
+// NewReads creates a new Reads
+func NewReads() *Reads {
+	return &Reads{
+		m: make(map[string]*read),
+	}
+}
+
+// Start marks the key as being read
+func (pr *Reads) Start(key string) {
+	pr.mtx.Lock()
+	defer pr.mtx.Unlock()
+
+	r, ok := pr.m[key]
+	if !ok {
+		r = &read{}
+		pr.m[key] = r
+	}
+	r.refs++
+}
+
+// Finish marks the read of the key as finished and execute the setFn
+// if there is no invalidation since the read started
+func (pr *Reads) Finish(key string, setFn func()) {
+	pr.mtx.Lock()
+	defer pr.mtx.Unlock()
+
+	r, ok := pr.m[key]
+	if !ok {
+		return
+	}
+	r.refs--
+	if r.refs == 0 {
+		delete(pr.m, key)
+	}
+	if !r.invalidated {
+		setFn()
+	}
+}
+
+// FinishAll marks the read of all the keys as finished and execute the setFn
+// if there is no invalidation of any of the keys since the read started
+func (pr *Reads) FinishAll(keys []string, setFn func()) {
+	pr.mtx.Lock()
+	defer pr.mtx.Unlock()
+
+	invalidated := false
+	for _, key := range keys {
+		r, ok := pr.m[key]
+		if !ok {
+			// the read of the key has been finished before, it is unknown
+			// whether the key was invalidated
+			invalidated = true
+			continue
+		}
+		r.refs--
+		if r.refs == 0 {
+			delete(pr.m, key)
+		}
+		invalidated = invalidated || r.invalidated
+	}
+	if !invalidated {
+		setFn()
+	}
+}
+
+// Invalidate marks the key as invalidated if it is being read
+// and execute the delFn
+func (pr *Reads) Invalidate(key string, delFn func()) {
+	pr.mtx.Lock()
+	defer pr.mtx.Unlock()
+
+	if r, ok := pr.m[key]; ok {
+		r.invalidated = true
+	}
+	delFn()
+}
+
+// InvalidateAll marks all of the keys being read as invalidated
+// and execute the delFn
+func (pr *Reads) InvalidateAll(delFn func()) {
+	pr.mtx.Lock()
+	defer pr.mtx.Unlock()
+
+	for _, r := range pr.m {
+		r.invalidated = true
+	}
+	delFn()
+}
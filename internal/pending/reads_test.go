@@ -0,0 +1,70 @@
+package pending
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// value read before the invalidation must not be cached
+func TestReads_InvalidateBeforeFinish(t *testing.T) {
+	pr := NewReads()
+
+	pr.Start("key_1")
+	pr.Start("key_2")
+
+	var deleted bool
+	pr.Invalidate("key_1", func() { deleted = true })
+	require.True(t, deleted)
+
+	var set1, set2 bool
+	pr.Finish("key_1", func() { set1 = true })
+	pr.Finish("key_2", func() { set2 = true })
+
+	require.False(t, set1)
+	require.True(t, set2)
+	require.Empty(t, pr.m)
+}
+
+// value read from multiple keys must not be cached if one of them is invalidated
+func TestReads_FinishAll(t *testing.T) {
+	pr := NewReads()
+
+	keys := []string{"key_1", "key_2"}
+	for _, key := range keys {
+		pr.Start(key)
+	}
+	pr.Invalidate("key_2", func() {})
+
+	var set bool
+	pr.FinishAll(keys, func() { set = true })
+	require.False(t, set)
+	require.Empty(t, pr.m)
+
+	for _, key := range keys {
+		pr.Start(key)
+	}
+	pr.FinishAll(keys, func() { set = true })
+	require.True(t, set)
+	require.Empty(t, pr.m)
+}
+
+// values read before the cache is cleared must not be cached
+func TestReads_InvalidateAll(t *testing.T) {
+	pr := NewReads()
+
+	pr.Start("key_1")
+
+	var cleared bool
+	pr.InvalidateAll(func() { cleared = true })
+	require.True(t, cleared)
+
+	pr.Start("key_2")
+
+	var set1, set2 bool
+	pr.Finish("key_1", func() { set1 = true })
+	pr.Finish("key_2", func() { set2 = true })
+
+	require.False(t, set1)
+	require.True(t, set2)
+}
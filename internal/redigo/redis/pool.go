@@ -432,8 +432,11 @@ func (p *Pool) dial(ctx context.Context, isDialCb bool) (Conn, error) {
 	}
 	rawConn.clientID = clientID
 
-	// TODO: what if the callback failed, should we close it?
-	return redisConn, p.DialCb(ctx, redisConn)
+	if err := p.DialCb(ctx, redisConn); err != nil {
+		redisConn.Close()
+		return nil, err
+	}
+	return redisConn, nil
 }
 
 func (p *Pool) put(pc *poolConn, forceClose bool) error {
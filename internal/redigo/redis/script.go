@@ -60,6 +60,11 @@ func (s *Script) Hash() string {
 	return s.hash
 }
 
+// Src returns the script source.
+func (s *Script) Src() string {
+	return s.src
+}
+
 // Do evaluates the script. Under the covers, Do optimistically evaluates the
 // script using the EVALSHA command. If the command fails because the script is
 // not loaded, then Do evaluates the script using the EVAL command (thus
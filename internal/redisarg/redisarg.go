@@ -0,0 +1,37 @@
+// Package redisarg formats the redis command arguments,
+// so the values are stored the same way by all of the rimcu clients, regardless of the protocol.
+package redisarg
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/iwanbk/rimcu/internal/redigo/redis"
+)
+
+// Format formats the argument the same way as the redigo connection does
+func Format(arg interface{}) []byte {
+	switch v := arg.(type) {
+	case []byte:
+		return v
+	case string:
+		return []byte(v)
+	case int:
+		return strconv.AppendInt(nil, int64(v), 10)
+	case int64:
+		return strconv.AppendInt(nil, v, 10)
+	case float64:
+		return strconv.AppendFloat(nil, v, 'g', -1, 64)
+	case bool:
+		if v {
+			return []byte("1")
+		}
+		return []byte("0")
+	case nil:
+		return []byte{}
+	case redis.Argument:
+		return Format(v.RedisArg())
+	default:
+		return []byte(fmt.Sprint(v))
+	}
+}
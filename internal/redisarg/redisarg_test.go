@@ -0,0 +1,27 @@
+package redisarg
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestFormat(t *testing.T) {
+	testCases := []struct {
+		arg      interface{}
+		expected string
+	}{
+		{arg: []byte("val"), expected: "val"},
+		{arg: "val", expected: "val"},
+		{arg: 10, expected: "10"},
+		{arg: int64(-10), expected: "-10"},
+		{arg: 1.5, expected: "1.5"},
+		{arg: true, expected: "1"},
+		{arg: false, expected: "0"},
+		{arg: nil, expected: ""},
+		{arg: uint8(7), expected: "7"},
+	}
+	for _, tc := range testCases {
+		require.Equal(t, tc.expected, string(Format(tc.arg)))
+	}
+}
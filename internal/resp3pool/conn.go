@@ -1,10 +1,12 @@
 package resp3pool
 
 import (
+	"bufio"
 	"context"
 	"errors"
+	"fmt"
 	"net"
-	"sync"
+	"strconv"
 	"time"
 
 	"github.com/iwanbk/resp3"
@@ -16,10 +18,16 @@ var (
 	ErrNotFound = errors.New("not found")
 
 	ErrPoolExhausted = errors.New("rimcu pool exhausted")
+
+	// ErrPoolClosed returned when the pool already closed
+	ErrPoolClosed = errors.New("rimcu pool closed")
 )
 
 // Conn is a single redis connection.
 //
+// The invalidation messages of the keys read by this connection are redirected
+// to the pool's listener, so this connection only receives command replies.
+//
 // It is not safe to use it concurrently
 type Conn struct {
 	// reader & writer for redis server communication
@@ -27,65 +35,57 @@ type Conn struct {
 	rd   *resp3.Reader
 	conn net.Conn
 
-	respCh chan *resp3.Value // TODO: add resp counter
+	pool *Pool
 
-	pool   *Pool
-	stopCh chan struct{}
+	// client ID of the listener which receives the invalidation messages
+	// of this connection
+	redirectID int64
 
-	// callback func to call when a slot become not valid anymore.
-	// it could happen in two conditions:
-	// - it receives invalidation message from redis
-	// - it's connection is closed, we should invalidates all slots
-	invalidCb InvalidateCbFunc
+	started bool
 
-	mtx     sync.Mutex
-	runFlag bool
+	// broken is set when the connection is in unknown state
+	// and can't be reused anymore
+	broken bool
 
 	logger logger.Logger
 }
 
-func newConn(netConn net.Conn, pool *Pool, invalidCb InvalidateCbFunc) *Conn {
+func newConn(netConn net.Conn, pool *Pool) *Conn {
 	return &Conn{
-		rd:        resp3.NewReader(netConn),
-		w:         resp3.NewWriter(netConn),
-		conn:      netConn,
-		pool:      pool,
-		respCh:    make(chan *resp3.Value),
-		stopCh:    make(chan struct{}),
-		invalidCb: invalidCb,
-		logger:    pool.logger,
+		rd:     resp3.NewReader(netConn),
+		w:      resp3.NewWriter(netConn),
+		conn:   netConn,
+		pool:   pool,
+		logger: pool.logger,
 	}
 }
 
-func (conn *Conn) start() error {
-	conn.mtx.Lock()
-
-	if conn.runFlag { // already run
-		conn.mtx.Unlock()
+// start initializes the connection:
+// - switch to RESP3 protocol
+// - enable tracking with redirection to the given listener's client ID
+func (conn *Conn) start(redirectID int64) error {
+	if conn.started {
 		return nil
 	}
-	conn.runFlag = true
-	conn.mtx.Unlock()
-
-	conn.run() // run in background
 
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 
 	_, err := conn.do(ctx, "hello", "3")
 	if err != nil {
-		conn.Close()
 		return err
 	}
 	// TODO : check result value
 
-	_, err = conn.do(ctx, "CLIENT", "TRACKING", "ON")
+	_, err = conn.do(ctx, "CLIENT", "TRACKING", "ON", "REDIRECT", redirectID)
 	if err != nil {
-		conn.Close()
 		return err
 	}
 	// TODO : check result value
 
+	conn.redirectID = redirectID
+	conn.started = true
+
 	return nil
 }
 
@@ -95,24 +95,9 @@ func (c *Conn) Close() {
 	c.pool.putConnBack(c)
 }
 
-// close the connection and kill itself
-func (c *Conn) closeExit() {
-	c.pool.releaseConn()
-	c.destroy()
-}
-
 // destroy this connection make it invalid to be used
 func (c *Conn) destroy() {
-	c.mtx.Lock()
-	runFlag := c.runFlag
-	c.mtx.Unlock()
-
 	c.conn.Close()
-
-	if runFlag {
-		c.stopCh <- struct{}{}
-	}
-
 }
 
 func (c *Conn) Do(ctx context.Context, cmd interface{}, args ...interface{}) (*resp3.Value, error) {
@@ -120,74 +105,125 @@ func (c *Conn) Do(ctx context.Context, cmd interface{}, args ...interface{}) (*r
 	return c.do(ctx, cmds...)
 }
 
-func (c *Conn) do(ctx context.Context, args ...interface{}) (*resp3.Value, error) {
-	if err := c.w.SendCommands(args...); err != nil {
+// Pipeline sends all of the commands at once and then reads their replies.
+//
+// The error replies are returned as the values, the returned error means
+// the connection failure which makes all of the replies unknown.
+func (c *Conn) Pipeline(ctx context.Context, cmds [][]interface{}) ([]*resp3.Value, error) {
+	dl, _ := ctx.Deadline()
+	c.conn.SetDeadline(dl)
+
+	for _, args := range cmds {
+		if err := writeCommand(c.w.Writer, args); err != nil {
+			// part of the commands might have been sent
+			c.broken = true
+			return nil, err
+		}
+	}
+	if err := c.w.Flush(); err != nil {
+		c.broken = true
 		return nil, err
 	}
 
-	select {
-	case val := <-c.respCh:
-		return val, nil
-	case <-ctx.Done():
-		// if it failed, something might went wrong.
-		// it is simpler to close this connection
-		c.closeExit()
-		return nil, ctx.Err()
+	replies := make([]*resp3.Value, 0, len(cmds))
+	for len(replies) < len(cmds) {
+		resp, _, err := c.rd.ReadValue()
+		if err != nil {
+			c.broken = true
+			if ctxErr := ctx.Err(); ctxErr != nil {
+				return nil, ctxErr
+			}
+			return nil, err
+		}
+		if resp.Type == resp3.TypePush {
+			c.handlePush(resp)
+			continue
+		}
+		replies = append(replies, resp)
 	}
+	return replies, nil
 }
 
-func (c *Conn) run() {
-	go func() {
-		defer func() {
-			c.mtx.Lock()
-			c.runFlag = false
-			c.mtx.Unlock()
-		}()
-		for {
-			select {
-			case <-c.stopCh:
-				return
-			default:
-			}
+func (c *Conn) do(ctx context.Context, args ...interface{}) (*resp3.Value, error) {
+	dl, _ := ctx.Deadline()
+	c.conn.SetDeadline(dl)
 
-			// read val
-			resp, _, err := c.rd.ReadValue()
-			if err != nil {
-				c.logger.Debugf("failed to receive a message: %v", err)
-				continue
-			}
+	if err := c.w.SendCommands(args...); err != nil {
+		c.broken = true
+		return nil, err
+	}
 
-			// send to respCh if not push notif
-			if resp.Type != resp3.TypePush {
-				//log.Printf("[non-push resp] %v:%v", resp.Type, resp.SmartResult())
-				c.respCh <- resp
-				continue
+	for {
+		resp, _, err := c.rd.ReadValue()
+		if err != nil {
+			// if it failed, something might went wrong.
+			// it is simpler to not reuse this connection
+			c.broken = true
+			if ctxErr := ctx.Err(); ctxErr != nil {
+				return nil, ctxErr
 			}
-			c.logger.Debugf("[PUSH resp] %v", resp.Type)
+			return nil, err
+		}
 
-			// handle invalidation
-			c.checkHandleInvalidation(resp)
+		if resp.Type == resp3.TypePush {
+			c.handlePush(resp)
+			continue
 		}
-	}()
+		return resp, nil
+	}
 }
 
-// check if it is invalidation message and handle it:
-// - remove the tracking of this slot
-// - execute the provided callback
-func (c *Conn) checkHandleInvalidation(resp *resp3.Value) {
-	if resp.Type != resp3.TypePush || len(resp.Elems) < 2 || resp.Elems[0].SmartResult().(string) != "invalidate" {
+// handlePush handles the push message received by the connection.
+//
+// The invalidation messages are redirected to the listener, but other push messages
+// could still arrive here, e.g. `tracking-redir-broken` when the listener is disconnected.
+func (c *Conn) handlePush(resp *resp3.Value) {
+	if len(resp.Elems) == 0 {
 		return
 	}
-
-	r := resp.Elems[1]
-	c.logger.Debugf("str:%v", r.Elems[0].SmartResult().(string))
-
-	key, ok := r.Elems[0].SmartResult().(string)
-	if !ok {
-		c.logger.Errorf("push notif doesn't have expected type: string")
+	if msgType, ok := resp.Elems[0].SmartResult().(string); !ok || msgType != "tracking-redir-broken" {
+		c.logger.Debugf("[PUSH resp] %v", resp.Type)
 		return
 	}
 
-	c.invalidCb(key)
+	// the invalidation messages of the keys read by this connection are lost,
+	// the connection is destroyed and the tracked keys are cleared when it is put back to the pool
+	c.logger.Errorf("tracking redirection to client ID %v is broken", c.redirectID)
+	c.broken = true
+}
 
+// writeCommand writes the command as RESP array of blob strings without flushing it,
+// the arguments are formatted the same way as the resp3.Writer does
+func writeCommand(w *bufio.Writer, args []interface{}) error {
+	w.WriteByte(resp3.TypeArray)
+	w.WriteString(strconv.Itoa(len(args)))
+	w.WriteString("\r\n")
+
+	for _, arg := range args {
+		var str string
+		switch v := arg.(type) {
+		case string:
+			str = v
+		case int64:
+			str = strconv.FormatInt(v, 10)
+		case int:
+			str = strconv.Itoa(v)
+		case []byte:
+			str = string(v)
+		case bool:
+			str = strconv.FormatBool(v)
+		case float32:
+			str = strconv.FormatFloat(float64(v), 'g', -1, 64)
+		case float64:
+			str = strconv.FormatFloat(v, 'g', -1, 64)
+		default:
+			return fmt.Errorf("unsupported data type: %v", arg)
+		}
+		w.WriteByte(resp3.TypeBlobString)
+		w.WriteString(strconv.Itoa(len(str)))
+		w.WriteString("\r\n")
+		w.WriteString(str)
+		w.WriteString("\r\n")
+	}
+	return nil
 }
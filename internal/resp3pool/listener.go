@@ -0,0 +1,203 @@
+package resp3pool
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/iwanbk/resp3"
+	"github.com/iwanbk/rimcu/logger"
+)
+
+// listener is a dedicated RESP3 connection which receives all of the
+// invalidation messages of the pool connections.
+//
+// The pool connections enable the tracking with `REDIRECT` to the client ID of
+// this listener, so the invalidation messages are not lost when the pool connection
+// which read the key is closed.
+type listener struct {
+	serverAddr string
+	logger     logger.Logger
+
+	// callback to call when we receive invalidation message of a key
+	invalidateCb InvalidateCbFunc
+
+	// callback to call when all the tracked keys can't be considered valid anymore
+	// because the listener connection is disconnected
+	clearCb func()
+
+	// callback to call when the server flushed it's database
+	flushCb func()
+
+	mtx      sync.Mutex
+	conn     net.Conn
+	clientID int64
+	running  bool
+	closed   bool
+}
+
+func newListener(serverAddr string, invalidateCb InvalidateCbFunc, clearCb, flushCb func(),
+	logger logger.Logger) *listener {
+	return &listener{
+		serverAddr:   serverAddr,
+		invalidateCb: invalidateCb,
+		clearCb:      clearCb,
+		flushCb:      flushCb,
+		logger:       logger,
+	}
+}
+
+// ensure makes sure the listener is connected and returns it's client ID.
+//
+// It connects to the server if the listener is not running yet
+// or it was disconnected.
+func (l *listener) ensure() (int64, error) {
+	l.mtx.Lock()
+	defer l.mtx.Unlock()
+
+	if l.closed {
+		return 0, ErrPoolClosed
+	}
+	if l.running {
+		return l.clientID, nil
+	}
+
+	conn, err := net.DialTimeout("tcp", l.serverAddr, 5*time.Second)
+	if err != nil {
+		return 0, err
+	}
+
+	var (
+		rd = resp3.NewReader(conn)
+		w  = resp3.NewWriter(conn)
+	)
+
+	clientID, err := l.handshake(conn, rd, w)
+	if err != nil {
+		conn.Close()
+		return 0, err
+	}
+
+	l.logger.Debugf("[listener] connected with client ID = %v", clientID)
+
+	l.conn = conn
+	l.clientID = clientID
+	l.running = true
+
+	go l.run(conn, rd)
+
+	return clientID, nil
+}
+
+// handshake switches the connection to RESP3 and gets it's client ID
+func (l *listener) handshake(conn net.Conn, rd *resp3.Reader, w *resp3.Writer) (int64, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if dl, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(dl)
+		defer conn.SetDeadline(time.Time{})
+	}
+
+	if err := w.SendCommands("HELLO", "3"); err != nil {
+		return 0, err
+	}
+	if _, err := readReply(rd); err != nil {
+		return 0, err
+	}
+
+	if err := w.SendCommands("CLIENT", "ID"); err != nil {
+		return 0, err
+	}
+	resp, err := readReply(rd)
+	if err != nil {
+		return 0, err
+	}
+	if resp.Type != resp3.TypeNumber {
+		return 0, fmt.Errorf("unexpected CLIENT ID reply type: %c", resp.Type)
+	}
+	return resp.Integer, nil
+}
+
+// run reads the push messages until the connection is broken
+func (l *listener) run(conn net.Conn, rd *resp3.Reader) {
+	for {
+		resp, _, err := rd.ReadValue()
+		if err != nil {
+			l.logger.Errorf("[listener] failed to read message: %v", err)
+			break
+		}
+		l.handlePush(resp)
+	}
+
+	conn.Close()
+
+	l.mtx.Lock()
+	if l.conn == conn {
+		l.running = false
+		l.conn = nil
+	}
+	l.mtx.Unlock()
+
+	// we can't assume that the cached values are still valid
+	// because we might miss some invalidation messages
+	l.clearCb()
+}
+
+// handlePush handles the invalidation push message:
+// - `invalidate` followed by array of keys
+// - `invalidate` followed by null, when the server flush the database
+func (l *listener) handlePush(resp *resp3.Value) {
+	if resp.Type != resp3.TypePush || len(resp.Elems) < 2 {
+		return
+	}
+	if msgType, ok := resp.Elems[0].SmartResult().(string); !ok || msgType != "invalidate" {
+		return
+	}
+
+	keys := resp.Elems[1]
+	if keys.Type == resp3.TypeNull {
+		l.logger.Debugf("[listener] got flush invalidation")
+		l.flushCb()
+		return
+	}
+
+	for _, elem := range keys.Elems {
+		key, ok := elem.SmartResult().(string)
+		if !ok {
+			l.logger.Errorf("push notif doesn't have expected type: string")
+			continue
+		}
+		l.invalidateCb(key)
+	}
+}
+
+// close the listener connection
+func (l *listener) close() {
+	l.mtx.Lock()
+	defer l.mtx.Unlock()
+
+	l.closed = true
+	if l.conn != nil {
+		l.conn.Close()
+	}
+}
+
+// readReply reads a non push value from the reader
+func readReply(rd *resp3.Reader) (*resp3.Value, error) {
+	for {
+		resp, _, err := rd.ReadValue()
+		if err != nil {
+			return nil, err
+		}
+		if resp.Type == resp3.TypePush {
+			continue
+		}
+		if resp.Type == resp3.TypeSimpleError || resp.Type == resp3.TypeBlobError {
+			return nil, fmt.Errorf("%s", resp.Err)
+		}
+		return resp, nil
+	}
+}
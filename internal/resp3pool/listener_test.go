@@ -0,0 +1,56 @@
+package resp3pool
+
+import (
+	"context"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// Test that the listener disconnection clears the cache
+// and the pool reconnects the listener on the next Get
+func TestListener_Disconnect(t *testing.T) {
+	var (
+		clearCh   = make(chan struct{}, 1)
+		ctx       = context.Background()
+		redisAddr = "localhost:6379"
+	)
+	if envAddr := os.Getenv("TEST_REDIS_ADDRESS"); envAddr != "" {
+		redisAddr = envAddr
+	}
+
+	pool := NewPool(PoolConfig{
+		ServerAddr: redisAddr,
+		ClearCb: func() {
+			select {
+			case clearCh <- struct{}{}:
+			default:
+			}
+		},
+	})
+	defer pool.Close()
+
+	conn, err := pool.Get(ctx)
+	require.NoError(t, err)
+	oldRedirectID := conn.redirectID
+
+	// kill the listener
+	_, err = conn.Do(ctx, "CLIENT", "KILL", "ID", oldRedirectID)
+	require.NoError(t, err)
+	conn.Close()
+
+	select {
+	case <-clearCh:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("clear callback is not called after 5 seconds")
+	}
+
+	// next Get must use the new listener
+	conn, err = pool.Get(ctx)
+	require.NoError(t, err)
+	defer conn.Close()
+
+	require.NotEqual(t, oldRedirectID, conn.redirectID)
+}
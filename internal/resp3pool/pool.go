@@ -11,10 +11,13 @@ import (
 
 // Pool represents a pool of connection.
 //
-// It is currently very simple untested pool
+// All connections of the pool redirect their invalidation messages
+// to a single listener connection.
 type Pool struct {
-	serverAddr   string
-	invalidateCb InvalidateCbFunc
+	serverAddr string
+
+	// listener of the invalidation messages
+	listener *listener
 
 	mtx   sync.Mutex
 	conns []*Conn
@@ -31,7 +34,17 @@ type PoolConfig struct {
 	ServerAddr   string
 	MaxConns     int // default:50
 	InvalidateCb InvalidateCbFunc
-	Logger       logger.Logger
+
+	// ClearCb is called when all of the tracked keys must be considered invalid.
+	// It happens when the listener is disconnected, a connection which tracks the keys
+	// is destroyed, or the server flushed the database and the FlushCb is nil.
+	ClearCb func()
+
+	// FlushCb is called when the server flushed the database,
+	// the ClearCb is called if it is nil.
+	FlushCb func()
+
+	Logger logger.Logger
 }
 
 // NewPool creates new connection pool from the given server address
@@ -42,11 +55,20 @@ func NewPool(cfg PoolConfig) *Pool {
 	if cfg.Logger == nil {
 		cfg.Logger = logger.NewDefault()
 	}
+	if cfg.InvalidateCb == nil {
+		cfg.InvalidateCb = func(string) {}
+	}
+	if cfg.ClearCb == nil {
+		cfg.ClearCb = func() {}
+	}
+	if cfg.FlushCb == nil {
+		cfg.FlushCb = cfg.ClearCb
+	}
 	return &Pool{
-		serverAddr:   cfg.ServerAddr,
-		invalidateCb: cfg.InvalidateCb,
-		maxConnsCh:   make(chan struct{}, cfg.MaxConns),
-		logger:       cfg.Logger,
+		serverAddr: cfg.ServerAddr,
+		listener:   newListener(cfg.ServerAddr, cfg.InvalidateCb, cfg.ClearCb, cfg.FlushCb, cfg.Logger),
+		maxConnsCh: make(chan struct{}, cfg.MaxConns),
+		logger:     cfg.Logger,
 	}
 }
 
@@ -57,11 +79,32 @@ type InvalidateCbFunc func(string)
 // ctx is context being used to wait when the pool is exhausted.
 // it should have timeout to avoid waiting indefinitely
 func (p *Pool) Get(ctx context.Context) (*Conn, error) {
+	// make sure the listener is running before handing out connection,
+	// otherwise the invalidation messages would be lost
+	redirectID, err := p.listener.ensure()
+	if err != nil {
+		return nil, err
+	}
+
 	conn, err := p.get(ctx)
 	if err != nil {
 		return nil, err
 	}
-	return conn, conn.start()
+
+	if conn.started && conn.redirectID != redirectID {
+		// the listener was reconnected, the tracking of this connection
+		// redirects to the old listener.
+		conn.broken = true
+		conn.Close()
+		return p.Get(ctx)
+	}
+
+	if err := conn.start(redirectID); err != nil {
+		conn.broken = true
+		conn.Close()
+		return nil, err
+	}
+	return conn, nil
 }
 
 func (p *Pool) get(ctx context.Context) (*Conn, error) {
@@ -78,7 +121,7 @@ func (p *Pool) get(ctx context.Context) (*Conn, error) {
 	}
 
 	// dial
-	conn, err := p.dial(p.invalidateCb)
+	conn, err := p.dial()
 	if err != nil {
 		<-p.maxConnsCh
 		return nil, err
@@ -105,19 +148,29 @@ func (p *Pool) getConnFromPool() (*Conn, bool) {
 }
 
 // dial create new connection
-func (p *Pool) dial(invalidCb InvalidateCbFunc) (*Conn, error) {
+func (p *Pool) dial() (*Conn, error) {
 	c, err := net.DialTimeout("tcp", p.serverAddr, 5*time.Second)
 	if err != nil {
 		return nil, err
 	}
-	return newConn(c, p, invalidCb), nil
+	return newConn(c, p), nil
 }
 
-// putConnBack put the connection back to the the end of the pool
+// putConnBack put the connection back to the the end of the pool.
+//
+// broken connection is destroyed instead, the server drops the tracking state
+// of the closed connection, so all of the tracked keys are cleared
 func (p *Pool) putConnBack(conn *Conn) {
-	p.mtx.Lock()
-	p.conns = append(p.conns, conn)
-	p.mtx.Unlock()
+	if conn.broken {
+		conn.destroy()
+		if conn.started {
+			p.listener.clearCb()
+		}
+	} else {
+		p.mtx.Lock()
+		p.conns = append(p.conns, conn)
+		p.mtx.Unlock()
+	}
 	p.releaseConn()
 }
 
@@ -127,9 +180,12 @@ func (p *Pool) releaseConn() {
 }
 
 func (p *Pool) Close() {
+	p.listener.close()
+
 	p.mtx.Lock()
 	defer p.mtx.Unlock()
 	for _, conn := range p.conns {
 		conn.destroy()
 	}
+	p.conns = nil
 }
@@ -51,3 +51,42 @@ func TestPool_Max(t *testing.T) {
 	require.NoError(t, err)
 	conn.Close()
 }
+
+// Test that destroying the tracking connection clears the tracked keys
+func TestPool_DestroyTrackingConn(t *testing.T) {
+	srv, err := miniredis.Run()
+	require.NoError(t, err)
+	defer srv.Close()
+
+	var numClears int
+
+	pool := NewPool(PoolConfig{
+		ServerAddr: srv.Addr(),
+		ClearCb: func() {
+			numClears++
+		},
+	})
+	defer pool.Close()
+
+	// broken connection which doesn't track any key
+	conn, err := pool.get(context.Background())
+	require.NoError(t, err)
+	conn.broken = true
+	conn.Close()
+	require.Equal(t, 0, numClears)
+
+	// broken connection which tracks the keys
+	conn, err = pool.get(context.Background())
+	require.NoError(t, err)
+	conn.started = true
+	conn.broken = true
+	conn.Close()
+	require.Equal(t, 1, numClears)
+
+	// healthy connection is put back to the pool
+	conn, err = pool.get(context.Background())
+	require.NoError(t, err)
+	conn.started = true
+	conn.Close()
+	require.Equal(t, 1, numClears)
+}
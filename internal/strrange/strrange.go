@@ -0,0 +1,29 @@
+// Package strrange implements the redis GETRANGE semantic on the local values
+package strrange
+
+// Range returns the substring of the value between the start and end offsets (both inclusive).
+//
+// Negative offsets are counted from the end of the value,
+// and the offsets are limited to the length of the value, the same as redis GETRANGE.
+func Range(val []byte, start, end int64) []byte {
+	length := int64(len(val))
+	if start < 0 {
+		start += length
+	}
+	if end < 0 {
+		end += length
+	}
+	if start < 0 {
+		start = 0
+	}
+	if end < 0 {
+		end = 0
+	}
+	if end >= length {
+		end = length - 1
+	}
+	if length == 0 || start > end {
+		return []byte{}
+	}
+	return val[start : end+1]
+}
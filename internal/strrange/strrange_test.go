@@ -0,0 +1,29 @@
+package strrange
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRange(t *testing.T) {
+	val := []byte("This is a string")
+
+	testCases := []struct {
+		start, end int64
+		expected   string
+	}{
+		{0, 3, "This"},
+		{-3, -1, "ing"},
+		{0, -1, "This is a string"},
+		{10, 100, "string"},
+		{5, 3, ""},
+		{-100, 3, "This"},
+		{20, 30, ""},
+	}
+	for _, tc := range testCases {
+		require.Equal(t, tc.expected, string(Range(val, tc.start, tc.end)), "%d-%d", tc.start, tc.end)
+	}
+
+	require.Equal(t, "", string(Range(nil, 0, -1)))
+}
@@ -0,0 +1,195 @@
+// Package watch delivers the change notifications of the watched keys
+package watch
+
+import (
+	"context"
+	"sync"
+)
+
+// Registry is the registry of the watchers of the keys.
+//
+// It also records the tracker (connection) of the watched keys,
+// so the keys could be tracked again when the tracker is gone.
+type Registry struct {
+	mtx      sync.Mutex
+	watchers map[string]map[*watcher]struct{}
+	trackers map[string]int64
+}
+
+// NewRegistry creates a new empty Registry
+func NewRegistry() *Registry {
+	return &Registry{
+		watchers: make(map[string]map[*watcher]struct{}),
+		trackers: make(map[string]int64),
+	}
+}
+
+// Watch registers a watcher of the given keys.
+//
+// The returned channel receives the key when it is changed, the pending notifications
+// of the same key are coalesced. The channel is closed when the ctx is done or the
+// returned cancel func is called.
+func (r *Registry) Watch(ctx context.Context, keys []string) (<-chan string, context.CancelFunc) {
+	ctx, cancel := context.WithCancel(ctx)
+	w := &watcher{
+		ch:      make(chan string),
+		signal:  make(chan struct{}, 1),
+		pending: make(map[string]struct{}),
+	}
+
+	r.mtx.Lock()
+	for _, key := range keys {
+		ws, ok := r.watchers[key]
+		if !ok {
+			ws = make(map[*watcher]struct{})
+			r.watchers[key] = ws
+		}
+		ws[w] = struct{}{}
+	}
+	r.mtx.Unlock()
+
+	go func() {
+		w.run(ctx)
+		r.unwatch(w, keys)
+		close(w.ch)
+	}()
+	return w.ch, cancel
+}
+
+func (r *Registry) unwatch(w *watcher, keys []string) {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+
+	for _, key := range keys {
+		ws := r.watchers[key]
+		delete(ws, w)
+		if len(ws) == 0 {
+			delete(r.watchers, key)
+			delete(r.trackers, key)
+		}
+	}
+}
+
+// Notify notifies the watchers of the key,
+// it returns false if the key is not watched
+func (r *Registry) Notify(key string) bool {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+
+	ws, ok := r.watchers[key]
+	if !ok {
+		return false
+	}
+	for w := range ws {
+		w.notify(key)
+	}
+	delete(r.trackers, key)
+	return true
+}
+
+// NotifyAll notifies the watchers of all keys and returns the watched keys
+func (r *Registry) NotifyAll() []string {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+
+	keys := make([]string, 0, len(r.watchers))
+	for key, ws := range r.watchers {
+		for w := range ws {
+			w.notify(key)
+		}
+		keys = append(keys, key)
+	}
+	r.trackers = make(map[string]int64)
+	return keys
+}
+
+// Watched returns the keys which are still watched
+func (r *Registry) Watched(keys []string) []string {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+
+	var watched []string
+	for _, key := range keys {
+		if _, ok := r.watchers[key]; ok {
+			watched = append(watched, key)
+		}
+	}
+	return watched
+}
+
+// SetTracker records the tracker of the watched keys
+func (r *Registry) SetTracker(keys []string, tracker int64) {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+
+	for _, key := range keys {
+		if _, ok := r.watchers[key]; ok {
+			r.trackers[key] = tracker
+		}
+	}
+}
+
+// TakeTracked removes and returns the watched keys tracked by the given tracker
+func (r *Registry) TakeTracked(tracker int64) []string {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+
+	var keys []string
+	for key, t := range r.trackers {
+		if t == tracker {
+			keys = append(keys, key)
+			delete(r.trackers, key)
+		}
+	}
+	return keys
+}
+
+// watcher delivers the notifications to it's channel
+type watcher struct {
+	ch     chan string
+	signal chan struct{}
+
+	mtx     sync.Mutex
+	pending map[string]struct{}
+	order   []string
+}
+
+// notify queues the key without blocking
+func (w *watcher) notify(key string) {
+	w.mtx.Lock()
+	if _, ok := w.pending[key]; !ok {
+		w.pending[key] = struct{}{}
+		w.order = append(w.order, key)
+	}
+	w.mtx.Unlock()
+
+	select {
+	case w.signal <- struct{}{}:
+	default:
+	}
+}
+
+// run delivers the queued keys until the ctx is done
+func (w *watcher) run(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-w.signal:
+		}
+
+		w.mtx.Lock()
+		keys := w.order
+		w.order = nil
+		w.pending = make(map[string]struct{})
+		w.mtx.Unlock()
+
+		for _, key := range keys {
+			select {
+			case w.ch <- key:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,58 @@
+package watch
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRegistry_Watch(t *testing.T) {
+	r := NewRegistry()
+
+	ch, cancel := r.Watch(context.Background(), []string{"key_1", "key_2"})
+
+	require.False(t, r.Notify("key_3"))
+
+	// the pending notifications of the same key are coalesced
+	require.True(t, r.Notify("key_1"))
+	require.True(t, r.Notify("key_1"))
+	require.True(t, r.Notify("key_2"))
+
+	require.Equal(t, "key_1", receive(t, ch))
+	require.Equal(t, "key_2", receive(t, ch))
+
+	cancel()
+	for range ch {
+	}
+	require.Empty(t, r.Watched([]string{"key_1", "key_2"}))
+}
+
+func TestRegistry_Trackers(t *testing.T) {
+	r := NewRegistry()
+
+	ch, cancel := r.Watch(context.Background(), []string{"key_1", "key_2"})
+	defer cancel()
+
+	r.SetTracker([]string{"key_1", "key_3"}, 10)
+	r.SetTracker([]string{"key_2"}, 20)
+
+	require.Equal(t, []string{"key_1"}, r.TakeTracked(10))
+	require.Empty(t, r.TakeTracked(10))
+
+	require.ElementsMatch(t, []string{"key_1", "key_2"}, r.NotifyAll())
+	require.Empty(t, r.TakeTracked(20))
+
+	receive(t, ch)
+}
+
+func receive(t *testing.T, ch <-chan string) string {
+	select {
+	case key := <-ch:
+		return key
+	case <-time.After(time.Second):
+		t.Fatal("timeout waiting for the notification")
+		return ""
+	}
+}
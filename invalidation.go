@@ -0,0 +1,42 @@
+package rimcu
+
+import (
+	"github.com/iwanbk/rimcu/result"
+)
+
+// InvalidationReason is the reason of the in memory cache invalidation
+type InvalidationReason = result.InvalidationReason
+
+const (
+	// InvalidationServer means the key was modified,
+	// the invalidation message was sent by the server or other rimcu clients
+	InvalidationServer = result.InvalidationServer
+	// InvalidationFlush means the server flushed the database
+	InvalidationFlush = result.InvalidationFlush
+	// InvalidationReconnect means the invalidation messages might be lost
+	// because the subscriber was disconnected
+	InvalidationReconnect = result.InvalidationReconnect
+	// InvalidationEviction means the key was evicted because the in memory cache is full
+	InvalidationEviction = result.InvalidationEviction
+	// InvalidationExpiry means the key was expired in the in memory cache
+	InvalidationExpiry = result.InvalidationExpiry
+)
+
+// OnInvalidate registers the func to be called when the key is removed from the in memory cache.
+//
+// The key is empty when any of the keys might be removed, i.e. on the flush and reconnect.
+// The func is called synchronously by the invalidation handler, it must not block
+// or call the StringsCache.
+// The eviction is not reported by the ProtoResp3 protocol.
+func (sc *StringsCache) OnInvalidate(fn func(key string, reason InvalidationReason)) {
+	sc.engine.OnInvalidate(func(key string, reason InvalidationReason) {
+		if key == "" {
+			fn(key, reason)
+			return
+		}
+		// the keys outside of the prefix are not ours, e.g. received by the pubsub mode
+		if key, ok := sc.stripKey(key); ok {
+			fn(key, reason)
+		}
+	})
+}
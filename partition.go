@@ -0,0 +1,65 @@
+package rimcu
+
+import (
+	"fmt"
+
+	"github.com/iwanbk/rimcu/result"
+)
+
+// PartitionConfig is the config of the in memory cache partition which hosts the keys
+// with the given prefix, the prefix is relative to the KeyPrefix of the StringsCache.
+//
+// The CacheSize is the size quota of the partition, default is the CacheSize of the StringsCache.
+type PartitionConfig = result.PartitionConfig
+
+// CacheStats is the stats of the in memory cache partition
+type CacheStats = result.CacheStats
+
+// Partition returns the StringsCache of the keys with the given prefix,
+// it shares the connections, subscriber, and in memory cache with this cache.
+//
+// The keys, the Compute names, and the tags are put under the prefix,
+// and the keys are cached in the partition configured
+// for the prefix, so one partition never evicts the keys of the other partitions.
+// The keys without configured partition are cached in the default partition.
+func (sc *StringsCache) Partition(prefix string) *StringsCache {
+	return &StringsCache{
+		engine:          sc.engine,
+		txMaxRetries:    sc.txMaxRetries,
+		keyPrefix:       sc.key(prefix),
+		partitionPrefix: sc.partitionPrefix + prefix,
+	}
+}
+
+// Stats returns the stats of the in memory cache partition which hosts the keys of this cache.
+//
+// The evictions are not counted by the ProtoResp3 protocol.
+func (sc *StringsCache) Stats() CacheStats {
+	return sc.engine.Stats(sc.keyPrefix)
+}
+
+// enginePartitions returns the partitions config with the prefix under the key prefix
+func enginePartitions(keyPrefix string, cacheSize int, parts []PartitionConfig) ([]result.PartitionConfig, error) {
+	var (
+		engineParts = make([]result.PartitionConfig, 0, len(parts))
+		prefixes    = make(map[string]struct{}, len(parts))
+	)
+	for _, p := range parts {
+		if p.Prefix == "" {
+			return nil, fmt.Errorf("empty partition prefix")
+		}
+		if _, ok := prefixes[p.Prefix]; ok {
+			return nil, fmt.Errorf("duplicated partition prefix: %q", p.Prefix)
+		}
+		prefixes[p.Prefix] = struct{}{}
+
+		if p.CacheSize <= 0 {
+			p.CacheSize = cacheSize
+		}
+		engineParts = append(engineParts, result.PartitionConfig{
+			Prefix:    keyPrefix + p.Prefix,
+			CacheSize: p.CacheSize,
+		})
+	}
+	return engineParts, nil
+}
@@ -0,0 +1,82 @@
+package rimcu
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestEnginePartitions(t *testing.T) {
+	parts, err := enginePartitions("svc:", 100, []PartitionConfig{
+		{Prefix: "tenant1:", CacheSize: 10},
+		{Prefix: "tenant2:"},
+	})
+	require.NoError(t, err)
+	require.Equal(t, []PartitionConfig{
+		{Prefix: "svc:tenant1:", CacheSize: 10},
+		{Prefix: "svc:tenant2:", CacheSize: 100},
+	}, parts)
+
+	_, err = enginePartitions("", 100, []PartitionConfig{{Prefix: ""}})
+	require.Error(t, err)
+
+	_, err = enginePartitions("", 100, []PartitionConfig{{Prefix: "a:"}, {Prefix: "a:"}})
+	require.Error(t, err)
+}
+
+// partitionTestEngine caches the computed values by name and records the invalidated tags
+type partitionTestEngine struct {
+	stringsCacheEngine
+
+	computed map[string]interface{}
+	tags     []string
+}
+
+func (e *partitionTestEngine) Compute(ctx context.Context, name string, deps []string, exp int,
+	fn func(ctx context.Context) (interface{}, error)) (interface{}, error) {
+	if val, ok := e.computed[name]; ok {
+		return val, nil
+	}
+	val, err := fn(ctx)
+	if err == nil {
+		e.computed[name] = val
+	}
+	return val, err
+}
+
+func (e *partitionTestEngine) InvalidateTag(ctx context.Context, tag string) error {
+	e.tags = append(e.tags, tag)
+	return nil
+}
+
+// the computed values and the tags must not be shared between the partitions
+func TestStringsCache_Partition_ComputeAndTag(t *testing.T) {
+	var (
+		ctx    = context.Background()
+		engine = &partitionTestEngine{computed: make(map[string]interface{})}
+		sc     = &StringsCache{engine: engine, keyPrefix: "svc:"}
+		t1     = sc.Partition("t1:")
+		t2     = sc.Partition("t2:")
+	)
+
+	for _, tc := range []struct {
+		sc   *StringsCache
+		want string
+	}{
+		{sc: t1, want: "t1"},
+		{sc: t2, want: "t2"},
+		{sc: t1, want: "t1"},
+	} {
+		val, err := tc.sc.Compute(ctx, "total", []string{"dep"}, 10, func(ctx context.Context) (interface{}, error) {
+			return tc.want, nil
+		})
+		require.NoError(t, err)
+		require.Equal(t, tc.want, val)
+	}
+
+	require.NoError(t, t1.InvalidateTag(ctx, "site"))
+	require.NoError(t, t2.InvalidateTag(ctx, "site"))
+	require.NoError(t, sc.InvalidateTag(ctx, "site"))
+	require.Equal(t, []string{"t1:site", "t2:site", "site"}, engine.tags)
+}
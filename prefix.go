@@ -0,0 +1,70 @@
+package rimcu
+
+import (
+	"context"
+	"strings"
+
+	"github.com/iwanbk/rimcu/result"
+)
+
+// key returns the key under the key prefix
+func (sc *StringsCache) key(key string) string {
+	return sc.keyPrefix + key
+}
+
+// keys returns the keys under the key prefix
+func (sc *StringsCache) keys(keys []string) []string {
+	if sc.keyPrefix == "" {
+		return keys
+	}
+	prefixed := make([]string, 0, len(keys))
+	for _, key := range keys {
+		prefixed = append(prefixed, sc.key(key))
+	}
+	return prefixed
+}
+
+// tag returns the tag under the partition prefix,
+// the tag index key is put under the key prefix by the engine
+func (sc *StringsCache) tag(tag string) string {
+	return sc.partitionPrefix + tag
+}
+
+// tags returns the tags under the partition prefix
+func (sc *StringsCache) tags(tags []string) []string {
+	if sc.partitionPrefix == "" {
+		return tags
+	}
+	prefixed := make([]string, 0, len(tags))
+	for _, tag := range tags {
+		prefixed = append(prefixed, sc.tag(tag))
+	}
+	return prefixed
+}
+
+// stripKey removes the key prefix from the key,
+// it returns false if the key doesn't have the prefix
+func (sc *StringsCache) stripKey(key string) (string, bool) {
+	if !strings.HasPrefix(key, sc.keyPrefix) {
+		return "", false
+	}
+	return key[len(sc.keyPrefix):], true
+}
+
+// prefixTx is the transaction which puts the keys under the key prefix
+type prefixTx struct {
+	sc *StringsCache
+	tx result.Tx
+}
+
+func (ptx prefixTx) Get(ctx context.Context, key string) (result.StringsResult, error) {
+	return ptx.tx.Get(ctx, ptx.sc.key(key))
+}
+
+func (ptx prefixTx) Setex(key string, val interface{}, exp int) {
+	ptx.tx.Setex(ptx.sc.key(key), val, exp)
+}
+
+func (ptx prefixTx) Del(keys ...string) {
+	ptx.tx.Del(ptx.sc.keys(keys)...)
+}
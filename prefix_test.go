@@ -0,0 +1,30 @@
+package rimcu
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestStringsCache_KeyPrefix(t *testing.T) {
+	sc := &StringsCache{keyPrefix: "svc:"}
+
+	require.Equal(t, "svc:key", sc.key("key"))
+	require.Equal(t, []string{"svc:a", "svc:b"}, sc.keys([]string{"a", "b"}))
+
+	key, ok := sc.stripKey("svc:key")
+	require.True(t, ok)
+	require.Equal(t, "key", key)
+
+	_, ok = sc.stripKey("other:key")
+	require.False(t, ok)
+
+	// no prefix
+	sc = &StringsCache{}
+	keys := []string{"a", "b"}
+	require.Equal(t, keys, sc.keys(keys))
+
+	key, ok = sc.stripKey("other:key")
+	require.True(t, ok)
+	require.Equal(t, "other:key", key)
+}
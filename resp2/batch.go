@@ -0,0 +1,177 @@
+package resp2
+
+import (
+	"context"
+
+	"github.com/iwanbk/rimcu/internal/redigo/redis"
+	"github.com/iwanbk/rimcu/result"
+)
+
+// pipelinedOp is a batch operation which sent to the server
+type pipelinedOp struct {
+	op   *result.BatchOp
+	cmd  string
+	args []interface{}
+}
+
+// ExecBatch executes the batch operations.
+//
+// The Get of the key which exists in the memory cache, and not written by the previous
+// operations in the batch, is answered from the memory cache.
+// The rest of the operations are sent in one pipeline using a single connection.
+//
+// The result of each operation is stored in the operation, the returned error
+// is the connection error which makes the results of the pipelined operations unknown.
+func (sc *StringsCache) ExecBatch(ctx context.Context, ops []*result.BatchOp) error {
+	var (
+		pipelined []pipelinedOp
+		written   = make(map[string]struct{})
+	)
+	for _, op := range ops {
+		switch op.Type {
+		case result.BatchGet:
+			key := op.Keys[0]
+			if _, ok := written[key]; !ok {
+				if val, ok := sc.getMemCache(key); ok {
+					if val == nil {
+						op.Err = ErrNotFound
+					} else {
+						op.Res = newStringResult(rawVal(val), true)
+					}
+					continue
+				}
+			}
+			pipelined = append(pipelined, pipelinedOp{op: op, cmd: "GET", args: []interface{}{key}})
+
+		case result.BatchSetex:
+			val, err := sc.writeVal(op.Val)
+			if err != nil {
+				op.Err = err
+				continue
+			}
+			written[op.Keys[0]] = struct{}{}
+			pipelined = append(pipelined, pipelinedOp{
+				op:   op,
+				cmd:  "SET",
+				args: []interface{}{op.Keys[0], val, "EX", op.Exp},
+			})
+
+		case result.BatchDel:
+			for _, key := range op.Keys {
+				written[key] = struct{}{}
+				sc.cc.Del(key)
+			}
+			pipelined = append(pipelined, pipelinedOp{op: op, cmd: "DEL", args: redis.Args{}.AddFlat(op.Keys)})
+		}
+	}
+	if len(pipelined) == 0 {
+		return nil
+	}
+
+	conn, err := sc.getConn(ctx)
+	if err != nil {
+		setBatchErr(pipelined, err)
+		return err
+	}
+	defer conn.Close()
+
+	for _, p := range pipelined {
+		if p.op.Type == result.BatchGet {
+			sc.pending.Start(p.op.Keys[0])
+		}
+	}
+	err = sc.pipeline(conn, pipelined)
+	sc.invalidateBatch(conn, pipelined)
+	return err
+}
+
+// pipeline sends the operations and receives their replies
+func (sc *StringsCache) pipeline(conn *redis.ActiveConn, pipelined []pipelinedOp) error {
+	var connErr error
+	for _, p := range pipelined {
+		if connErr = conn.Send(p.cmd, p.args...); connErr != nil {
+			break
+		}
+	}
+	if connErr == nil {
+		connErr = conn.Flush()
+	}
+
+	for i, p := range pipelined {
+		var (
+			reply interface{}
+			err   = connErr
+		)
+		if connErr == nil {
+			reply, err = conn.Receive()
+			if _, ok := err.(redis.Error); !ok && err != nil {
+				// not an error reply, the connection is broken
+				connErr = err
+			}
+		}
+		sc.checkClusterError(err)
+
+		switch p.op.Type {
+		case result.BatchGet:
+			sc.finishBatchGet(conn, p.op, reply, err)
+		case result.BatchSetex:
+			if err == nil {
+				_, err = redis.String(reply, nil)
+			}
+			p.op.Err = err
+		case result.BatchDel:
+			p.op.Err = err
+		}
+
+		if connErr != nil {
+			setBatchErr(pipelined[i+1:], connErr)
+			for _, rest := range pipelined[i+1:] {
+				if rest.op.Type == result.BatchGet {
+					sc.pending.Finish(rest.op.Keys[0], func() {})
+				}
+			}
+			return connErr
+		}
+	}
+	return nil
+}
+
+// finishBatchGet sets the result of the pipelined Get,
+// and caches the value in the memory cache
+func (sc *StringsCache) finishBatchGet(conn *redis.ActiveConn, op *result.BatchOp, reply interface{}, err error) {
+	key := op.Keys[0]
+	if err == nil && reply != nil {
+		reply, err = sc.readVal(reply, nil)
+	}
+	sc.pending.Finish(key, func() {
+		if err == nil && (reply != nil || sc.cacheNegative) {
+			sc.setMemCache(key, reply, conn.ClientID(), op.Exp)
+		}
+	})
+	if err == nil && reply == nil {
+		err = ErrNotFound
+	}
+	op.Res = newStringResult(rawVal(reply), false)
+	op.Err = err
+}
+
+// invalidateBatch invalidates the keys written by the batch
+func (sc *StringsCache) invalidateBatch(conn redis.Conn, pipelined []pipelinedOp) {
+	for _, p := range pipelined {
+		if p.op.Type == result.BatchGet || p.op.Err != nil {
+			continue
+		}
+		for _, key := range p.op.Keys {
+			sc.cc.Del(key)
+			if err := sc.publishInvalidation(conn, key); err != nil {
+				p.op.Err = err
+			}
+		}
+	}
+}
+
+func setBatchErr(pipelined []pipelinedOp, err error) {
+	for _, p := range pipelined {
+		p.op.Err = err
+	}
+}
@@ -1,59 +1,210 @@
 package resp2
 
 import (
+	"sync"
+	"sync/atomic"
 	"time"
 
-	"github.com/bluele/gcache"
+	"github.com/iwanbk/rimcu/internal/cluster"
+	"github.com/iwanbk/rimcu/result"
 )
 
 // cache is in-memory cache of the resp2 rimcu
 type cache struct {
-	valCache gcache.Cache
-	ckm      *connKeyMap
+	// parts are the partitions of the cached values, ordered by their prefix length.
+	// The last one is the default partition which prefix is empty
+	parts []*partition
+	ckm   *connKeyMap
+
+	// skm maps the keys to their cluster slot,
+	// only being used in cluster mode
+	skm *slotKeyMap
+
+	// nkm maps the keys to the replica they were read from
+	nkm *nodeKeyMap
+
+	// dkm maps the keys to the cached keys which depend on them
+	dkm *depKeyMap
+
+	// slots stores generation of the cluster hash slots,
+	// only being used in slot invalidation mode.
+	// The key maps above are not used in this mode.
+	slots *slotGens
+
+	// evictCb is called when the key is evicted or expired, it is optional.
+	// removing marks the keys being removed by us, which are not reported
+	evictCb  func(key string, reason result.InvalidationReason)
+	removing sync.Map
 }
 
 // cacheVal represents a cache value
 type cacheVal struct {
 	val      interface{}
 	clientID int64 // TODO: move this info to `ckm`
+	node     string
+	slot     uint16
+	slotGen  uint64
+	deps     []string // keys which the value depends on
+	expireAt time.Time
+
+	// slots of the dep keys other than the first one, which slot is the slot above
+	depSlots    []uint16
+	depSlotGens []uint64
 }
 
-func newCache(size int) *cache {
+func newCache(size int, slotTracking, slotInvalidation bool) *cache {
 	c := &cache{
 		ckm: newConnKeyMap(),
+		nkm: newNodeKeyMap(),
+		dkm: newDepKeyMap(),
+	}
+	if slotInvalidation {
+		c.slots = newSlotGens()
+	} else if slotTracking {
+		c.skm = newSlotKeyMap()
 	}
 
-	valCache := gcache.New(size).LRU().
-		EvictedFunc(func(key, val interface{}) {
-			c.evictedKeyHandler(key, val)
-		}).Build()
-	c.valCache = valCache
+	c.parts = []*partition{c.newPartition("", size)}
 
 	return c
 }
 
-// TODO add test for this
-func (c *cache) evictedKeyHandler(key, val interface{}) {
+// evictedKeyHandler is called when the key is removed from the partition,
+// either evicted, expired, or removed by us
+func (c *cache) evictedKeyHandler(p *partition, key, val interface{}) {
 	// remove record in the client -> key mapping
 	cVal, ok := val.(cacheVal)
 	if !ok {
 		panic("]evictedKeyHandler] unpexpected type of cache value")
 	}
-	c.ckm.del(cVal.clientID, key.(string))
+	strKey := key.(string)
+	c.delKeyMaps(strKey, cVal)
+
+	if _, ok := c.removing.Load(strKey); ok {
+		return
+	}
+	reason := result.InvalidationExpiry
+	if time.Now().Before(cVal.expireAt) {
+		reason = result.InvalidationEviction
+		atomic.AddUint64(&p.evictions, 1)
+	}
+	if c.evictCb == nil || len(cVal.deps) > 0 {
+		// the values with dependencies are internal values
+		return
+	}
+	c.evictCb(strKey, reason)
 }
 
-// Set cache
+// Set cache.
+//
+// zero clientID means that the key is not tracked by the connection
 func (c *cache) Set(key string, val interface{}, clientID int64, expSecond int) {
-	c.ckm.add(clientID, key)
-	c.valCache.SetWithExpire(key, cacheVal{
+	if clientID != 0 && c.slots == nil {
+		c.ckm.add(clientID, key)
+	}
+	c.set(key, cacheVal{
+		val:      val,
+		clientID: clientID,
+	}, expSecond)
+}
+
+// SetWithDeps sets cache of the value which depends on the value of the given keys,
+// it is deleted when one of the keys is invalidated.
+//
+// The slot of the value is the slot of the first dep key
+func (c *cache) SetWithDeps(key string, val interface{}, clientID int64, expSecond int, deps []string) {
+	if clientID != 0 && c.slots == nil {
+		c.ckm.add(clientID, key)
+	}
+	for _, dep := range deps {
+		c.dkm.add(dep, key)
+	}
+	c.set(key, cacheVal{
 		val:      val,
 		clientID: clientID,
-	}, time.Second*time.Duration(expSecond))
+		deps:     deps,
+	}, expSecond)
+}
+
+// SetFromNode sets cache of the value which read from the given replica node
+func (c *cache) SetFromNode(key string, val interface{}, node string, expSecond int) {
+	if c.slots == nil {
+		c.nkm.add(node, key)
+	}
+	c.set(key, cacheVal{
+		val:  val,
+		node: node,
+	}, expSecond)
+}
+
+func (c *cache) set(key string, cVal cacheVal, expSecond int) {
+	if c.skm != nil || c.slots != nil {
+		// the value with deps is invalidated when the slot of any of the deps is invalidated
+		slotKey := key
+		if len(cVal.deps) > 0 {
+			slotKey = cVal.deps[0]
+		}
+		cVal.slot = cluster.Slot(slotKey)
+		for i := 1; i < len(cVal.deps); i++ {
+			cVal.depSlots = append(cVal.depSlots, cluster.Slot(cVal.deps[i]))
+		}
+	}
+	if c.skm != nil {
+		c.skm.add(key, cVal.slot)
+		for _, slot := range cVal.depSlots {
+			c.skm.add(key, slot)
+		}
+	}
+	if c.slots != nil {
+		cVal.slotGen = c.slots.get(cVal.slot)
+		for _, slot := range cVal.depSlots {
+			cVal.depSlotGens = append(cVal.depSlotGens, c.slots.get(slot))
+		}
+	}
+	exp := time.Second * time.Duration(expSecond)
+	cVal.expireAt = time.Now().Add(exp)
+	c.part(key).valCache.SetWithExpire(key, cVal, exp)
+}
+
+// remove the key from it's partition without reporting it as evicted
+func (c *cache) remove(key string) {
+	c.removing.Store(key, struct{}{})
+	defer c.removing.Delete(key)
+
+	c.part(key).valCache.Remove(key)
+}
+
+// delKeyMaps deletes the key from all of the key mappings
+func (c *cache) delKeyMaps(key string, cVal cacheVal) {
+	c.ckm.del(cVal.clientID, key)
+	if cVal.node != "" {
+		c.nkm.del(cVal.node, key)
+	}
+	if c.skm != nil {
+		c.skm.del(key, cVal.slot)
+		for _, slot := range cVal.depSlots {
+			c.skm.del(key, slot)
+		}
+	}
+	for _, dep := range cVal.deps {
+		c.dkm.del(dep, key)
+	}
 }
 
 // Get cache
 func (c *cache) Get(key string) (interface{}, bool) {
-	val, err := c.valCache.Get(key)
+	p := c.part(key)
+	val, ok := c.get(p, key)
+	if !ok {
+		atomic.AddUint64(&p.misses, 1)
+		return nil, false
+	}
+	atomic.AddUint64(&p.hits, 1)
+	return val, true
+}
+
+func (c *cache) get(p *partition, key string) (interface{}, bool) {
+	val, err := p.valCache.Get(key)
 	if err != nil {
 		return nil, false
 	}
@@ -63,12 +214,34 @@ func (c *cache) Get(key string) (interface{}, bool) {
 		return nil, false
 	}
 
+	if c.slots != nil && c.slotInvalidated(cVal) {
+		// the slot has been invalidated after the key was set
+		c.remove(key)
+		return nil, false
+	}
+
 	return cVal.val, true
 }
 
-// Del cache
+// slotInvalidated returns true if any of the slots of the value
+// has been invalidated after the value was set
+func (c *cache) slotInvalidated(cVal cacheVal) bool {
+	if c.slots.get(cVal.slot) != cVal.slotGen {
+		return true
+	}
+	for i, slot := range cVal.depSlots {
+		if c.slots.get(slot) != cVal.depSlotGens[i] {
+			return true
+		}
+	}
+	return false
+}
+
+// Del cache of the key and it's dependents
 func (c *cache) Del(key string) {
-	val, err := c.valCache.Get(key)
+	c.delDependents(key)
+
+	val, err := c.part(key).valCache.Get(key)
 	if err != nil {
 		return
 	}
@@ -78,11 +251,36 @@ func (c *cache) Del(key string) {
 		return
 	}
 
-	c.valCache.Remove(key)
-	c.ckm.del(cVal.clientID, key)
+	c.remove(key)
+	c.delKeyMaps(key, cVal)
+}
+
+// Invalidate the key.
+//
+// In slot invalidation mode, it invalidates all keys of the key's slot.
+func (c *cache) Invalidate(key string) {
+	if c.slots != nil {
+		// the dependents might be in other slot
+		c.delDependents(key)
+		c.slots.invalidate(cluster.Slot(key))
+		return
+	}
+	c.Del(key)
+}
+
+// delDependents deletes cache of the keys which depend on the given key
+func (c *cache) delDependents(key string) {
+	for _, dep := range c.dkm.keys(key) {
+		c.Del(dep)
+	}
 }
 
 func (c *cache) CleanCacheForConn(clientID int64) {
+	if c.slots != nil {
+		// the conn<->key mapping is not tracked in slot invalidation mode
+		c.slots.invalidateAll()
+		return
+	}
 	// clean all keys in cache
 	keys := c.ckm.keys(clientID)
 	for key := range keys {
@@ -92,6 +290,41 @@ func (c *cache) CleanCacheForConn(clientID int64) {
 	c.ckm.clean(clientID)
 }
 
+// CleanCacheForNode deletes all keys which read from the given replica node
+func (c *cache) CleanCacheForNode(node string) {
+	if c.slots != nil {
+		// the node<->key mapping is not tracked in slot invalidation mode
+		c.slots.invalidateAll()
+		return
+	}
+	for _, key := range c.nkm.keys(node) {
+		c.Del(key)
+	}
+	c.nkm.clean(node)
+}
+
+// CleanCacheForSlots deletes all keys which slot is inside the given ranges
+func (c *cache) CleanCacheForSlots(slots []cluster.SlotRange) {
+	if c.slots != nil {
+		c.slots.invalidateRanges(slots)
+		return
+	}
+	if c.skm == nil {
+		return
+	}
+	for _, key := range c.skm.keys(slots) {
+		c.Del(key)
+	}
+}
+
 func (c *cache) Clear() {
-	c.valCache.Purge()
+	for _, p := range c.parts {
+		p.valCache.Purge()
+	}
+	c.ckm.cleanAll()
+	c.nkm.cleanAll()
+	c.dkm.cleanAll()
+	if c.skm != nil {
+		c.skm.cleanAll()
+	}
 }
@@ -0,0 +1,195 @@
+package resp2
+
+import (
+	"testing"
+
+	"github.com/iwanbk/rimcu/internal/cluster"
+	"github.com/iwanbk/rimcu/result"
+	"github.com/stretchr/testify/require"
+)
+
+// CleanCacheForSlots must only delete keys of the given slots
+func TestCache_CleanCacheForSlots(t *testing.T) {
+	c := newCache(100, true, false)
+
+	var (
+		key1 = "foo" // slot 12182
+		key2 = "bar" // slot 5061
+	)
+	c.Set(key1, "val_1", 0, testExpSecond)
+	c.Set(key2, "val_2", 0, testExpSecond)
+
+	c.CleanCacheForSlots([]cluster.SlotRange{{Start: 10923, End: 16383}})
+
+	_, ok := c.Get(key1)
+	require.False(t, ok)
+
+	_, ok = c.Get(key2)
+	require.True(t, ok)
+}
+
+// CleanCacheForSlots must delete the values which any of their deps is in the given slots
+func TestCache_CleanCacheForSlots_Deps(t *testing.T) {
+	for _, slotInvalidation := range []bool{false, true} {
+		c := newCache(100, !slotInvalidation, slotInvalidation)
+
+		var (
+			dep1 = "bar" // slot 5061
+			dep2 = "foo" // slot 12182
+		)
+		c.SetWithDeps("computed", "val", 0, testExpSecond, []string{dep1, dep2})
+
+		c.CleanCacheForSlots([]cluster.SlotRange{{Start: 10923, End: 16383}})
+
+		_, ok := c.Get("computed")
+		require.False(t, ok)
+		if c.skm != nil {
+			require.Empty(t, c.skm.m)
+		}
+	}
+}
+
+// CleanCacheForNode must only delete keys read from the given node
+func TestCache_CleanCacheForNode(t *testing.T) {
+	c := newCache(100, false, false)
+
+	var (
+		replica1 = "127.0.0.1:6380"
+		replica2 = "127.0.0.1:6381"
+	)
+	c.SetFromNode("key_1", "val_1", replica1, testExpSecond)
+	c.SetFromNode("key_2", "val_2", replica2, testExpSecond)
+	c.Set("key_3", "val_3", 10, testExpSecond)
+
+	c.CleanCacheForNode(replica1)
+
+	_, ok := c.Get("key_1")
+	require.False(t, ok)
+
+	_, ok = c.Get("key_2")
+	require.True(t, ok)
+
+	_, ok = c.Get("key_3")
+	require.True(t, ok)
+}
+
+// Invalidate in slot invalidation mode must delete all keys of the slot
+func TestCache_SlotInvalidation(t *testing.T) {
+	c := newCache(100, false, true)
+
+	var (
+		key1 = "{user1}.name"
+		key2 = "{user1}.email"
+		key3 = "{user2}.name"
+	)
+	c.Set(key1, "val_1", 10, testExpSecond)
+	c.Set(key2, "val_2", 10, testExpSecond)
+	c.Set(key3, "val_3", 10, testExpSecond)
+
+	c.Invalidate(key1)
+
+	_, ok := c.Get(key1)
+	require.False(t, ok)
+
+	_, ok = c.Get(key2)
+	require.False(t, ok)
+
+	_, ok = c.Get(key3)
+	require.True(t, ok)
+
+	// the key set after the invalidation must be valid
+	c.Set(key1, "val_1", 10, testExpSecond)
+	_, ok = c.Get(key1)
+	require.True(t, ok)
+
+	// per key mappings must not be used
+	require.Empty(t, c.ckm.keys(10))
+}
+
+func TestCache_SetWithDeps(t *testing.T) {
+	testCases := []struct {
+		name             string
+		slotInvalidation bool
+	}{
+		{name: "key invalidation", slotInvalidation: false},
+		{name: "slot invalidation", slotInvalidation: true},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			c := newCache(100, false, tc.slotInvalidation)
+
+			var (
+				key1      = "{user1}.name"
+				key2      = "{user2}.name"
+				scriptKey = scriptCacheKey("hash", []string{key1, key2}, nil)
+			)
+			c.SetWithDeps(scriptKey, "result", 10, testExpSecond, []string{key1, key2})
+
+			_, ok := c.Get(scriptKey)
+			require.True(t, ok)
+
+			// invalidation of the dep key in other slot
+			c.Invalidate(key2)
+
+			_, ok = c.Get(scriptKey)
+			require.False(t, ok)
+			require.Empty(t, c.dkm.keys(key1))
+			require.Empty(t, c.dkm.keys(key2))
+		})
+	}
+}
+
+func TestCache_EvictCb(t *testing.T) {
+	c := newCache(2, false, false)
+
+	var reasons = make(map[string]result.InvalidationReason)
+	c.evictCb = func(key string, reason result.InvalidationReason) {
+		reasons[key] = reason
+	}
+
+	c.Set("key_1", "val_1", 10, testExpSecond)
+	c.Set("key_2", "val_2", 10, testExpSecond)
+	c.Set("key_3", "val_3", 10, 0)
+
+	// evicted by key_3
+	require.Equal(t, result.InvalidationEviction, reasons["key_1"])
+
+	_, ok := c.Get("key_3")
+	require.False(t, ok)
+	require.Equal(t, result.InvalidationExpiry, reasons["key_3"])
+
+	// deleted by us
+	c.Del("key_2")
+	require.NotContains(t, reasons, "key_2")
+}
+
+// keys of a partition must not evict the keys of the other partitions
+func TestCache_Partition(t *testing.T) {
+	c := newCache(2, false, false)
+	c.AddPartition("tenant1:", 1)
+
+	c.Set("key_1", "val_1", 10, testExpSecond)
+	c.Set("tenant1:key_1", "val_1", 10, testExpSecond)
+	c.Set("tenant1:key_2", "val_2", 10, testExpSecond)
+
+	_, ok := c.Get("key_1")
+	require.True(t, ok)
+	_, ok = c.Get("tenant1:key_1")
+	require.False(t, ok)
+	_, ok = c.Get("tenant1:key_2")
+	require.True(t, ok)
+
+	require.Equal(t, result.CacheStats{
+		Size:      1,
+		Capacity:  1,
+		Hits:      1,
+		Misses:    1,
+		Evictions: 1,
+	}, c.Stats("tenant1:"))
+
+	require.Equal(t, result.CacheStats{
+		Size:     1,
+		Capacity: 2,
+		Hits:     1,
+	}, c.Stats(""))
+}
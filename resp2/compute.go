@@ -0,0 +1,67 @@
+package resp2
+
+import (
+	"context"
+
+	"github.com/iwanbk/rimcu/internal/redigo/redis"
+)
+
+// computeKeyPrefix is the prefix of the in memory cache key of the computed values,
+// it can't be used by the redis keys
+const computeKeyPrefix = "\x00compute:"
+
+// Compute returns the value computed by the fn, the value is cached in the memory cache
+// under the given name and it is invalidated when one of the deps keys is invalidated.
+//
+// The deps keys are tracked before calling the fn, so the changes of the keys
+// during the computation are not missed.
+func (sc *StringsCache) Compute(ctx context.Context, name string, deps []string, expSecond int,
+	fn func(ctx context.Context) (interface{}, error)) (interface{}, error) {
+	if len(deps) == 0 {
+		// nothing to invalidate the computed value
+		return nil, ErrInvalidArgs
+	}
+
+	cacheKey := computeKeyPrefix + name
+	if val, ok := sc.getMemCache(cacheKey); ok {
+		return val, nil
+	}
+
+	for _, key := range deps {
+		sc.pending.Start(key)
+	}
+	clientID, err := sc.trackKeys(ctx, deps)
+
+	var val interface{}
+	if err == nil {
+		val, err = fn(ctx)
+	}
+	sc.pending.FinishAll(deps, func() {
+		if err == nil {
+			sc.setMemCacheWithDeps(cacheKey, val, clientID, expSecond, deps)
+		}
+	})
+	return val, err
+}
+
+// trackKeys makes the server track the given keys without reading their values,
+// it returns the client ID of the connection which tracks the keys.
+//
+// It is only needed when the keys are tracked per connection, other modes
+// receive the invalidation messages of all keys.
+func (sc *StringsCache) trackKeys(ctx context.Context, keys []string) (int64, error) {
+	if !sc.connTracking() {
+		return 0, nil
+	}
+
+	conn, err := sc.getConn(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer conn.Close()
+
+	if _, err = conn.Do("EXISTS", redis.Args{}.AddFlat(keys)...); err != nil {
+		return 0, err
+	}
+	return conn.ClientID(), nil
+}
@@ -49,7 +49,15 @@ func (ckm *connKeyMap) clean(clientID int64) {
 	delete(ckm.m, clientID)
 }
 
-// keys returns all keys associated with a client ID
+// cleanAll cleans conn<->key map of all clients
+func (ckm *connKeyMap) cleanAll() {
+	ckm.mtx.Lock()
+	defer ckm.mtx.Unlock()
+
+	ckm.m = make(map[int64]*keysMap)
+}
+
+// keys returns copy of all keys associated with a client ID
 func (ckm *connKeyMap) keys(clientID int64) map[string]struct{} {
 	ckm.mtx.Lock()
 	defer ckm.mtx.Unlock()
@@ -58,7 +66,12 @@ func (ckm *connKeyMap) keys(clientID int64) map[string]struct{} {
 	if !ok {
 		return make(map[string]struct{})
 	}
-	return km.m
+
+	keys := make(map[string]struct{}, len(km.m))
+	for key := range km.m {
+		keys[key] = struct{}{}
+	}
+	return keys
 }
 
 // keysMap is map of keys that hold by a client connection
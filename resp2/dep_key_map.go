@@ -0,0 +1,68 @@
+package resp2
+
+import "sync"
+
+// depKeyMap stores info about the cached keys which
+// depend on the value of other keys, e.g. the cached script results
+type depKeyMap struct {
+	mtx sync.Mutex
+	m   map[string]*keysMap
+}
+
+func newDepKeyMap() *depKeyMap {
+	return &depKeyMap{
+		m: make(map[string]*keysMap),
+	}
+}
+
+// adds key as the dependent of the dep key
+func (dkm *depKeyMap) add(dep, key string) {
+	dkm.mtx.Lock()
+	defer dkm.mtx.Unlock()
+
+	km, ok := dkm.m[dep]
+	if !ok {
+		km = newKeysMap()
+		dkm.m[dep] = km
+	}
+	km.add(key)
+}
+
+func (dkm *depKeyMap) del(dep, key string) {
+	dkm.mtx.Lock()
+	defer dkm.mtx.Unlock()
+
+	km, ok := dkm.m[dep]
+	if !ok {
+		return
+	}
+	km.del(key)
+	if len(km.m) == 0 {
+		delete(dkm.m, dep)
+	}
+}
+
+// cleanAll cleans dep<->key map of all keys
+func (dkm *depKeyMap) cleanAll() {
+	dkm.mtx.Lock()
+	defer dkm.mtx.Unlock()
+
+	dkm.m = make(map[string]*keysMap)
+}
+
+// keys returns copy of all dependents of the dep key
+func (dkm *depKeyMap) keys(dep string) []string {
+	dkm.mtx.Lock()
+	defer dkm.mtx.Unlock()
+
+	km, ok := dkm.m[dep]
+	if !ok {
+		return nil
+	}
+
+	keys := make([]string, 0, len(km.m))
+	for key := range km.m {
+		keys = append(keys, key)
+	}
+	return keys
+}
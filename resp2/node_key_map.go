@@ -0,0 +1,73 @@
+package resp2
+
+import "sync"
+
+// nodeKeyMap stores info about keys that
+// associated with redis node address
+type nodeKeyMap struct {
+	mtx sync.Mutex
+	m   map[string]*keysMap
+}
+
+func newNodeKeyMap() *nodeKeyMap {
+	return &nodeKeyMap{
+		m: make(map[string]*keysMap),
+	}
+}
+
+// adds key to the given node
+func (nkm *nodeKeyMap) add(addr, key string) {
+	nkm.mtx.Lock()
+	defer nkm.mtx.Unlock()
+
+	km, ok := nkm.m[addr]
+	if !ok {
+		km = newKeysMap()
+		nkm.m[addr] = km
+	}
+	km.add(key)
+}
+
+func (nkm *nodeKeyMap) del(addr, key string) {
+	nkm.mtx.Lock()
+	defer nkm.mtx.Unlock()
+
+	km, ok := nkm.m[addr]
+	if !ok {
+		return
+	}
+	km.del(key)
+}
+
+// clean cleans node<->key map of the node
+func (nkm *nodeKeyMap) clean(addr string) {
+	nkm.mtx.Lock()
+	defer nkm.mtx.Unlock()
+
+	delete(nkm.m, addr)
+}
+
+// cleanAll cleans node<->key map of all nodes
+func (nkm *nodeKeyMap) cleanAll() {
+	nkm.mtx.Lock()
+	defer nkm.mtx.Unlock()
+
+	nkm.m = make(map[string]*keysMap)
+}
+
+// keys returns copy of all keys associated with the node
+func (nkm *nodeKeyMap) keys(addr string) []string {
+	nkm.mtx.Lock()
+	defer nkm.mtx.Unlock()
+
+	km, ok := nkm.m[addr]
+	if !ok {
+		return nil
+	}
+
+	keys := make([]string, 0, len(km.m))
+	for key := range km.m {
+		keys = append(keys, key)
+	}
+	return keys
+}
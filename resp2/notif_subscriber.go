@@ -2,66 +2,215 @@ package resp2
 
 import (
 	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
 
+	"github.com/iwanbk/rimcu/internal/notif"
 	"github.com/iwanbk/rimcu/internal/redigo/redis"
 	logger "github.com/iwanbk/rimcu/logger"
 )
 
+// subscriberMode represents how the subscriber receives the invalidation messages
+type subscriberMode int
+
+const (
+	// subscriberTracking receives invalidation messages of the keys read by
+	// the connections which redirect their tracking to the subscriber
+	subscriberTracking subscriberMode = iota
+
+	// subscriberBcast receives invalidation messages of all keys modified on the node
+	subscriberBcast
+
+	// subscriberPubSub receives `notif.Notif` published by other rimcu clients,
+	// it doesn't need CLIENT TRACKING support on the server
+	subscriberPubSub
+)
+
 type notifSubcriber struct {
 	//pool              *redis.Pool
-	finishedCh        chan struct{}
 	logger            logger.Logger
-	disconnectHandler func()
+	disconnectHandler func(addr string, clientID int64)
+	flushHandler      func(addr string, clientID int64)
 	notifHandler      func(string)
-	clientID          int64
-	mode              Mode
+	mode              subscriberMode
+	channel           string
+
+	// bcastPrefix limits the broadcasting tracking to the keys with this prefix,
+	// empty means all keys
+	bcastPrefix string
+
+	// local subscriber ID, used in place of client ID in subscriberPubSub mode
+	// because the CLIENT command might be not available
+	localID int64
+
+	mtx sync.Mutex
+	// client ID of the connected subscriber of each node,
+	// zero if it is not connected
+	clientIDs map[string]int64
+
+	// stop channel of the running subscribers
+	stopChs map[string]chan struct{}
+}
+
+// notifNode is a redis node which sends the invalidation messages
+// to the subscriber
+type notifNode struct {
+	addr string
+	pool *redis.Pool
 }
 
-func newNotifSubcriber(notifHandler func(string), disconnectHandler func(),
-	mode Mode, logger logger.Logger) *notifSubcriber {
+// newNotifNodes creates notification node of the given addresses
+func newNotifNodes(addrs []string, opts []redis.DialOption, logger logger.Logger) []notifNode {
+	var nodes []notifNode
+	for _, addr := range addrs {
+		node := addr
+		pool := &redis.Pool{
+			Dial: func() (redis.Conn, error) {
+				logger.Debugf("[notif]dialing: %v", node)
+				return redis.Dial("tcp", node, opts...)
+			},
+		}
+		nodes = append(nodes, notifNode{
+			addr: node,
+			pool: pool,
+		})
+	}
+	return nodes
+}
+
+func newNotifSubcriber(notifHandler func(string), disconnectHandler, flushHandler func(addr string, clientID int64),
+	mode subscriberMode, channel, bcastPrefix string, logger logger.Logger) *notifSubcriber {
 	ns := &notifSubcriber{
 		//pool:              pool,
-		finishedCh:        make(chan struct{}),
 		logger:            logger,
 		notifHandler:      notifHandler,
 		disconnectHandler: disconnectHandler,
+		flushHandler:      flushHandler,
 		mode:              mode,
+		channel:           channel,
+		bcastPrefix:       bcastPrefix,
+		clientIDs:         make(map[string]int64),
+		stopChs:           make(map[string]chan struct{}),
 	}
 	return ns
 }
 
-// TODO: call it
+// Close stops all of the subscribers
 func (ns *notifSubcriber) Close() {
-	ns.finishedCh <- struct{}{}
+	for _, addr := range ns.runningAddrs() {
+		ns.stopSubscriber(addr)
+	}
 }
 
-func (ns *notifSubcriber) run(pools []*redis.Pool) error {
-	for _, pool := range pools {
-		if err := ns.runSubscriber(pool); err != nil {
+func (ns *notifSubcriber) run(nodes []notifNode) error {
+	for _, node := range nodes {
+		if err := ns.runSubscriber(node); err != nil {
 			return err
 		}
 	}
 	return nil
 }
 
-func (ns *notifSubcriber) runSubscriber(pool *redis.Pool) error {
-	subscriberDoneCh, err := ns.startSub(pool)
+// sync makes the running subscribers follow the given nodes:
+// - start subscriber of the new node
+// - stop subscriber of the node which is not exists anymore
+func (ns *notifSubcriber) sync(nodes []notifNode) {
+	var (
+		running = make(map[string]struct{})
+		wanted  = make(map[string]struct{})
+	)
+	for _, addr := range ns.runningAddrs() {
+		running[addr] = struct{}{}
+	}
+
+	for _, node := range nodes {
+		wanted[node.addr] = struct{}{}
+		if _, ok := running[node.addr]; ok {
+			continue
+		}
+		ns.logger.Debugf("[ns] starting subscriber of new node: %v", node.addr)
+		if err := ns.runSubscriber(node); err != nil {
+			ns.logger.Errorf("[ns] failed to start subscriber of %v: %v", node.addr, err)
+		}
+	}
+
+	for addr := range running {
+		if _, ok := wanted[addr]; ok {
+			continue
+		}
+		ns.logger.Debugf("[ns] stopping subscriber of removed node: %v", addr)
+		ns.stopSubscriber(addr)
+	}
+}
+
+func (ns *notifSubcriber) runningAddrs() []string {
+	ns.mtx.Lock()
+	defer ns.mtx.Unlock()
+
+	addrs := make([]string, 0, len(ns.stopChs))
+	for addr := range ns.stopChs {
+		addrs = append(addrs, addr)
+	}
+	return addrs
+}
+
+func (ns *notifSubcriber) stopSubscriber(addr string) {
+	ns.mtx.Lock()
+	stopCh, ok := ns.stopChs[addr]
+	delete(ns.stopChs, addr)
+	ns.mtx.Unlock()
+
+	if ok {
+		close(stopCh)
+	}
+}
+
+func (ns *notifSubcriber) runSubscriber(node notifNode) error {
+	subscriberDoneCh, sub, clientID, err := ns.startSub(node)
 	if err != nil {
+		node.pool.Close()
 		return err
 	}
+
+	stopCh := make(chan struct{})
+	ns.mtx.Lock()
+	ns.stopChs[node.addr] = stopCh
+	ns.mtx.Unlock()
+
 	go func() {
+		defer node.pool.Close()
 		for {
 			select {
-			case <-ns.finishedCh: // we are done
+			case <-stopCh: // we are done
+				if sub != nil {
+					// unsubscribe to stop the subscriber loop
+					sub.Unsubscribe()
+					<-subscriberDoneCh
+					sub.Close()
+				}
+				ns.resetClientID(node.addr, clientID)
 				return
 			case <-subscriberDoneCh:
-				// we're just disconnected from our Notif channel,
-				// clear our in mem cache as we can't assume that the values
-				// still updated
-				ns.disconnectHandler()
+				if sub != nil {
+					sub.Close()
+				}
+				if clientID != 0 {
+					// we're just disconnected from our Notif channel,
+					// clear the in mem cache which tracked by this subscriber
+					// as we can't assume that the values still updated
+					ns.resetClientID(node.addr, clientID)
+					ns.disconnectHandler(node.addr, clientID)
+				} else {
+					select {
+					case <-stopCh:
+						return
+					case <-time.After(resubscribeDelay):
+					}
+				}
 
 				// start new subscriber
-				subscriberDoneCh, err = ns.startSub(pool)
+				subscriberDoneCh, sub, clientID, err = ns.startSub(node)
 				if err != nil {
 					ns.logger.Errorf("failed to start subscriber: %v", err)
 				}
@@ -71,35 +220,59 @@ func (ns *notifSubcriber) runSubscriber(pool *redis.Pool) error {
 	return nil
 }
 
-// starts subscriber to listen to all of synchronization message sent by other nodes
-func (ns *notifSubcriber) startSub(pool *redis.Pool) (chan struct{}, error) {
+// getClientID returns client ID of the connected subscriber of the given node,
+// it returns zero if the subscriber is not connected.
+func (ns *notifSubcriber) getClientID(addr string) int64 {
+	ns.mtx.Lock()
+	defer ns.mtx.Unlock()
+	return ns.clientIDs[addr]
+}
+
+func (ns *notifSubcriber) setClientID(addr string, clientID int64) {
+	ns.mtx.Lock()
+	defer ns.mtx.Unlock()
+	ns.clientIDs[addr] = clientID
+}
+
+// resetClientID marks the subscriber with the given client ID as disconnected
+func (ns *notifSubcriber) resetClientID(addr string, clientID int64) {
+	ns.mtx.Lock()
+	defer ns.mtx.Unlock()
+	if ns.clientIDs[addr] == clientID {
+		ns.clientIDs[addr] = 0
+	}
+}
+
+// starts subscriber to listen to all of synchronization message sent by other nodes.
+//
+// It returns nil subscriber and zero client ID if it failed to start the subscriber.
+// The returned done channel is closed when the subscriber loop exited.
+func (ns *notifSubcriber) startSub(node notifNode) (chan struct{}, *redis.PubSubConn, int64, error) {
 	doneCh := make(chan struct{})
 
 	// setup subscriber
-	sub, err := ns.subscribe(pool)
+	sub, clientID, err := ns.subscribe(node.pool)
 	if err != nil {
 		close(doneCh)
-		return doneCh, err
+		return doneCh, nil, 0, err
 	}
 
-	// we're just connected to our Notif channel,
-	// it means we previously not connected or disconnected from the Notif channel.
-	ns.disconnectHandler()
-
 	//ns.logger.Debugf("WAITING FOR subscribed confirmation")
 	switch sub.Receive().(type) {
 	case redis.Subscription:
 		ns.logger.Debugf("SUBSCRIBED")
 	default:
+		sub.Close()
 		close(doneCh)
-		return doneCh, fmt.Errorf("failed to subscribe")
+		return doneCh, nil, 0, fmt.Errorf("failed to subscribe")
 	}
+
+	// the subscriber is ready to receive the invalidation messages
+	ns.setClientID(node.addr, clientID)
+
 	// run subscriber loop
 	go func() {
-		defer func() {
-			close(doneCh)
-			sub.Close()
-		}()
+		defer close(doneCh)
 
 		for {
 			//ns.logger.Debugf("[ns]WAITING FOR NOTIFICATION")
@@ -116,6 +289,10 @@ func (ns *notifSubcriber) startSub(pool *redis.Pool) (chan struct{}, error) {
 
 			// first value: message type
 			val1, err := redis.String(vals[0], nil)
+			if val1 == "unsubscribe" && err == nil {
+				ns.logger.Debugf("[ns] unsubscribed")
+				return
+			}
 			if val1 != "message" || err != nil {
 				ns.logger.Errorf("[ns] invalid first string:%v,err:%v", val1, err)
 				return
@@ -123,69 +300,101 @@ func (ns *notifSubcriber) startSub(pool *redis.Pool) (chan struct{}, error) {
 
 			// 2nd value: channel
 			val2, err := redis.String(vals[1], nil)
-			if val2 != invalidationChannel || err != nil {
+			if val2 != ns.channel || err != nil {
 				ns.logger.Errorf("[ns] invalid second string:%v,err:%v", val2, err)
 				return
 			}
 
-			// 3rd: keys
-			val3, err := redis.Values(vals[2], nil)
+			// 3rd: keys, or nil when the server flushed the database
+			if vals[2] == nil && ns.mode != subscriberPubSub {
+				ns.logger.Debugf("[ns] got flush invalidation")
+				ns.flushHandler(node.addr, clientID)
+				continue
+			}
+			keys, err := ns.decodeKeys(vals[2])
 			if err != nil {
-				ns.logger.Errorf("[ns] unexpected third msg string:%v,err:%v", val3, err)
+				ns.logger.Errorf("[ns] unexpected third msg:%v", err)
 				return
 			}
-			for i, val := range val3 {
-				key, err := redis.String(val, nil)
-				if err != nil {
-					ns.logger.Errorf("[ns] failed to read key %v: %v", i, err)
-					return
-				}
+			for _, key := range keys {
 				//ns.logger.Debugf("----> %v adalah %v", i, key)
 				ns.notifHandler(key)
 			}
 		}
 	}()
-	return doneCh, nil
+	return doneCh, sub, clientID, nil
+}
+
+// decodeKeys decodes the invalidated keys of the message payload
+func (ns *notifSubcriber) decodeKeys(payload interface{}) ([]string, error) {
+	if ns.mode == subscriberPubSub {
+		b, err := redis.Bytes(payload, nil)
+		if err != nil {
+			return nil, err
+		}
+		n, err := notif.Decode(b)
+		if err != nil {
+			return nil, err
+		}
+		return []string{n.Key}, nil
+	}
+	return redis.Strings(payload, nil)
 }
 
 // subscribe to the notification channel
-func (ns *notifSubcriber) subscribe(pool *redis.Pool) (*redis.PubSubConn, error) {
+func (ns *notifSubcriber) subscribe(pool *redis.Pool) (*redis.PubSubConn, int64, error) {
 	// get conn
 	conn := pool.Get()
 
 	if err := conn.Err(); err != nil {
-		return nil, err
+		return nil, 0, err
+	}
+
+	if ns.mode == subscriberPubSub {
+		sub := &redis.PubSubConn{Conn: conn}
+		if err := sub.Subscribe(ns.channel); err != nil {
+			sub.Close()
+			return nil, 0, err
+		}
+		return sub, atomic.AddInt64(&ns.localID, 1), nil
 	}
 
 	// get client ID
 	id, err := redis.Int64(conn.Do("CLIENT", "ID"))
 	if err != nil {
-		return nil, fmt.Errorf("client ID failed: %v", err)
+		conn.Close()
+		return nil, 0, fmt.Errorf("client ID failed: %v", err)
 	}
 
 	ns.logger.Debugf("client ID = %v", id)
 
-	ns.clientID = id
-
-	if ns.mode == ModeClusterProxy {
+	if ns.mode == subscriberBcast {
 		// set tracking
-		_, err = conn.Do("CLIENT", "TRACKING", "on", "REDIRECT", id, "BCAST")
+		args := redis.Args{"TRACKING", "on", "REDIRECT", id, "BCAST"}
+		if ns.bcastPrefix != "" {
+			args = append(args, "PREFIX", ns.bcastPrefix)
+		}
+		_, err = conn.Do("CLIENT", args...)
 		if err != nil {
-			return nil, fmt.Errorf("client tracking failed:%v", err)
+			conn.Close()
+			return nil, 0, fmt.Errorf("client tracking failed:%v", err)
 		}
 	}
 
 	sub := &redis.PubSubConn{Conn: conn}
 
-	err = sub.Subscribe(invalidationChannel)
+	err = sub.Subscribe(ns.channel)
 	if err != nil {
 		sub.Close()
-		return nil, err
+		return nil, 0, err
 	}
 
-	return sub, nil
+	return sub, id, nil
 }
 
 const (
 	invalidationChannel = "__redis__:invalidate"
+
+	// delay before retrying to start the subscriber
+	resubscribeDelay = time.Second
 )
@@ -0,0 +1,68 @@
+package resp2
+
+import (
+	"sort"
+	"strings"
+	"sync/atomic"
+
+	"github.com/bluele/gcache"
+	"github.com/iwanbk/rimcu/result"
+)
+
+// partition is a part of the in memory cache which has it's own size quota,
+// so the keys of a partition never evict the keys of the other partitions.
+type partition struct {
+	prefix   string
+	size     int
+	valCache gcache.Cache
+
+	hits      uint64
+	misses    uint64
+	evictions uint64
+}
+
+func (c *cache) newPartition(prefix string, size int) *partition {
+	p := &partition{
+		prefix: prefix,
+		size:   size,
+	}
+	p.valCache = gcache.New(size).LRU().
+		EvictedFunc(func(key, val interface{}) {
+			c.evictedKeyHandler(p, key, val)
+		}).Build()
+	return p
+}
+
+// AddPartition adds partition of the keys with the given prefix,
+// it must be called before the cache is used.
+func (c *cache) AddPartition(prefix string, size int) {
+	c.parts = append(c.parts, c.newPartition(prefix, size))
+
+	// the longest prefix is matched first, the default partition is the last
+	sort.SliceStable(c.parts, func(i, j int) bool {
+		return len(c.parts[i].prefix) > len(c.parts[j].prefix)
+	})
+}
+
+// part returns the partition of the key
+func (c *cache) part(key string) *partition {
+	for _, p := range c.parts {
+		if strings.HasPrefix(key, p.prefix) {
+			return p
+		}
+	}
+	// unreachable, the default partition matches all keys
+	return c.parts[len(c.parts)-1]
+}
+
+// Stats returns the stats of the partition of the keys with the given prefix
+func (c *cache) Stats(prefix string) result.CacheStats {
+	p := c.part(prefix)
+	return result.CacheStats{
+		Size:      p.valCache.Len(false),
+		Capacity:  p.size,
+		Hits:      atomic.LoadUint64(&p.hits),
+		Misses:    atomic.LoadUint64(&p.misses),
+		Evictions: atomic.LoadUint64(&p.evictions),
+	}
+}
@@ -0,0 +1,55 @@
+package resp2
+
+import "sync"
+
+// redirectMap stores client ID of the notification subscriber
+// which receives the invalidation messages of a connection.
+type redirectMap struct {
+	mtx sync.Mutex
+	m   map[int64]int64 // conn client ID -> subscriber client ID
+}
+
+func newRedirectMap() *redirectMap {
+	return &redirectMap{
+		m: make(map[int64]int64),
+	}
+}
+
+// set the subscriber client ID of the given connection
+func (rm *redirectMap) set(clientID, subscriberID int64) {
+	rm.mtx.Lock()
+	defer rm.mtx.Unlock()
+
+	rm.m[clientID] = subscriberID
+}
+
+// get the subscriber client ID of the given connection
+func (rm *redirectMap) get(clientID int64) (int64, bool) {
+	rm.mtx.Lock()
+	defer rm.mtx.Unlock()
+
+	subscriberID, ok := rm.m[clientID]
+	return subscriberID, ok
+}
+
+func (rm *redirectMap) del(clientID int64) {
+	rm.mtx.Lock()
+	defer rm.mtx.Unlock()
+
+	delete(rm.m, clientID)
+}
+
+// conns returns client ID of all connections which redirect
+// their invalidation messages to the given subscriber
+func (rm *redirectMap) conns(subscriberID int64) []int64 {
+	rm.mtx.Lock()
+	defer rm.mtx.Unlock()
+
+	var clientIDs []int64
+	for clientID, subID := range rm.m {
+		if subID == subscriberID {
+			clientIDs = append(clientIDs, clientID)
+		}
+	}
+	return clientIDs
+}
@@ -0,0 +1,137 @@
+package resp2
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+
+	"github.com/iwanbk/rimcu/internal/redigo/redis"
+	"github.com/iwanbk/rimcu/logger"
+)
+
+// replicaReader reads the keys from the replicas.
+//
+// Each replica has it's own notification subscriber with broadcasting tracking,
+// so the keys read from a replica are invalidated when the replica applies
+// the write from it's master, not when the master receives it.
+type replicaReader struct {
+	mtx   sync.Mutex
+	pools map[string]*redis.Pool
+
+	// round robin counter
+	next uint64
+
+	subscriber *notifSubcriber
+	dialOpts   []redis.DialOption
+
+	// readOnly sends READONLY command on the new connection,
+	// it is needed to read from the redis cluster replicas
+	readOnly bool
+
+	logger logger.Logger
+}
+
+func newReplicaReader(notifHandler func(string), disconnectHandler func(addr string, clientID int64),
+	dialOpts []redis.DialOption, readOnly bool, keyPrefix string, logger logger.Logger) *replicaReader {
+	return &replicaReader{
+		pools: make(map[string]*redis.Pool),
+		// the flush is handled as disconnect, the master subscriber also receives the flush
+		subscriber: newNotifSubcriber(notifHandler, disconnectHandler, disconnectHandler, subscriberBcast,
+			invalidationChannel, keyPrefix, logger),
+		dialOpts: dialOpts,
+		readOnly: readOnly,
+		logger:   logger,
+	}
+}
+
+// sync makes the reader follow the given replicas:
+// - creates pool and subscriber of the new replica
+// - closes pool and subscriber of the replica which is not exists anymore
+func (rr *replicaReader) sync(addrs []string) {
+	wanted := make(map[string]struct{}, len(addrs))
+
+	rr.mtx.Lock()
+	for _, addr := range addrs {
+		wanted[addr] = struct{}{}
+		if _, ok := rr.pools[addr]; !ok {
+			rr.pools[addr] = rr.newPool(addr)
+		}
+	}
+	for addr, pool := range rr.pools {
+		if _, ok := wanted[addr]; !ok {
+			pool.Close()
+			delete(rr.pools, addr)
+		}
+	}
+	rr.mtx.Unlock()
+
+	rr.subscriber.sync(newNotifNodes(addrs, rr.dialOpts, rr.logger))
+}
+
+func (rr *replicaReader) newPool(addr string) *redis.Pool {
+	return &redis.Pool{
+		Dial: func() (redis.Conn, error) {
+			conn, err := redis.Dial("tcp", addr, rr.dialOpts...)
+			if err != nil || !rr.readOnly {
+				return conn, err
+			}
+			if _, err := conn.Do("READONLY"); err != nil {
+				conn.Close()
+				return nil, err
+			}
+			return conn, nil
+		},
+		MaxActive: 100, // TODO: make it from config
+		MaxIdle:   100,
+	}
+}
+
+// pick one of the given replicas which invalidation messages
+// could be received, in round robin fashion.
+func (rr *replicaReader) pick(candidates []string) (string, bool) {
+	var tracked []string
+	for _, addr := range candidates {
+		if rr.isTracked(addr) {
+			tracked = append(tracked, addr)
+		}
+	}
+	if len(tracked) == 0 {
+		return "", false
+	}
+	idx := atomic.AddUint64(&rr.next, 1) % uint64(len(tracked))
+	return tracked[idx], true
+}
+
+// isTracked returns true if the subscriber of the replica is connected
+func (rr *replicaReader) isTracked(addr string) bool {
+	return rr.subscriber.getClientID(addr) != 0
+}
+
+// get value of the key from the given replica
+func (rr *replicaReader) get(ctx context.Context, addr, key string) (interface{}, error) {
+	rr.mtx.Lock()
+	pool, ok := rr.pools[addr]
+	rr.mtx.Unlock()
+	if !ok {
+		return nil, errReplicaNotFound
+	}
+
+	conn, err := pool.GetContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	return conn.Do("GET", key)
+}
+
+func (rr *replicaReader) close() {
+	rr.subscriber.Close()
+
+	rr.mtx.Lock()
+	defer rr.mtx.Unlock()
+	for addr, pool := range rr.pools {
+		pool.Close()
+		delete(rr.pools, addr)
+	}
+}
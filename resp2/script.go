@@ -0,0 +1,87 @@
+package resp2
+
+import (
+	"context"
+	"strconv"
+	"strings"
+
+	"github.com/iwanbk/rimcu/internal/redigo/redis"
+	"github.com/iwanbk/rimcu/internal/redisarg"
+	"github.com/iwanbk/rimcu/result"
+)
+
+// scriptKeyPrefix is the prefix of the in memory cache key of the script results,
+// it can't be used by the redis keys
+const scriptKeyPrefix = "\x00script:"
+
+// EvalRO evaluates the read only script with the given keys and args.
+//
+// The keys are the keys read by the script, the result is cached in the memory cache
+// keyed by the script hash & the arguments, and it is invalidated when
+// one of the keys is invalidated.
+// The script must be created with negative key count, the key count is given by the keys.
+func (sc *StringsCache) EvalRO(ctx context.Context, script *redis.Script, keys []string, expSecond int,
+	args ...interface{}) (result.StringsResult, error) {
+	if len(keys) == 0 {
+		// nothing to invalidate the cached result
+		return newStringResult(nil, false), ErrInvalidArgs
+	}
+
+	cacheKey := scriptCacheKey(script.Hash(), keys, args)
+	if val, ok := sc.getMemCache(cacheKey); ok {
+		return newStringResult(val, true), nil
+	}
+
+	conn, err := sc.getConn(ctx)
+	if err != nil {
+		return newStringResult(nil, false), err
+	}
+	defer conn.Close()
+
+	for _, key := range keys {
+		sc.pending.Start(key)
+	}
+	val, err := sc.evalRO(conn, script, keys, args)
+	sc.pending.FinishAll(keys, func() {
+		if err == nil {
+			sc.setMemCacheWithDeps(cacheKey, val, conn.ClientID(), expSecond, keys)
+		}
+	})
+	sc.checkClusterError(err)
+	return newStringResult(val, false), err
+}
+
+func (sc *StringsCache) evalRO(conn redis.Conn, script *redis.Script, keys []string, args []interface{}) (interface{}, error) {
+	if sc.connTracking() {
+		// the server might not track the keys read by the script,
+		// read them first using the same connection to make sure they are tracked
+		if err := conn.Send("EXISTS", redis.Args{}.AddFlat(keys)...); err != nil {
+			return nil, err
+		}
+	}
+	keysAndArgs := redis.Args{len(keys)}.AddFlat(keys).Add(args...)
+	return script.Do(conn, keysAndArgs...)
+}
+
+// scriptCacheKey returns the in memory cache key of the script result
+func scriptCacheKey(hash string, keys []string, args []interface{}) string {
+	var sb strings.Builder
+	sb.WriteString(scriptKeyPrefix)
+	sb.WriteString(hash)
+	sb.WriteString(":")
+	sb.WriteString(strconv.Itoa(len(keys)))
+	for _, key := range keys {
+		writeLenPrefixed(&sb, key)
+	}
+	for _, arg := range args {
+		writeLenPrefixed(&sb, string(redisarg.Format(arg)))
+	}
+	return sb.String()
+}
+
+func writeLenPrefixed(sb *strings.Builder, str string) {
+	sb.WriteString(":")
+	sb.WriteString(strconv.Itoa(len(str)))
+	sb.WriteString(":")
+	sb.WriteString(str)
+}
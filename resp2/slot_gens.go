@@ -0,0 +1,44 @@
+package resp2
+
+import (
+	"sync/atomic"
+
+	"github.com/iwanbk/rimcu/internal/cluster"
+)
+
+// slotGens stores generation of each cluster hash slot.
+//
+// Invalidating a slot increments it's generation, which makes
+// all the cached keys of the slot which set on the previous generation stale.
+// It only needs fixed amount of memory regardless of the number of the cached keys.
+type slotGens struct {
+	gens [cluster.NumSlots]uint64
+}
+
+func newSlotGens() *slotGens {
+	return &slotGens{}
+}
+
+// get returns current generation of the slot
+func (sg *slotGens) get(slot uint16) uint64 {
+	return atomic.LoadUint64(&sg.gens[slot])
+}
+
+// invalidate the given slot
+func (sg *slotGens) invalidate(slot uint16) {
+	atomic.AddUint64(&sg.gens[slot], 1)
+}
+
+// invalidateRanges invalidates all slots inside the given ranges
+func (sg *slotGens) invalidateRanges(slots []cluster.SlotRange) {
+	for _, sr := range slots {
+		for slot := int(sr.Start); slot <= int(sr.End); slot++ {
+			sg.invalidate(uint16(slot))
+		}
+	}
+}
+
+// invalidateAll invalidates all slots
+func (sg *slotGens) invalidateAll() {
+	sg.invalidateRanges([]cluster.SlotRange{{Start: 0, End: cluster.NumSlots - 1}})
+}
@@ -0,0 +1,75 @@
+package resp2
+
+import (
+	"sync"
+
+	"github.com/iwanbk/rimcu/internal/cluster"
+)
+
+// slotKeyMap stores info about keys that
+// associated with cluster hash slot
+type slotKeyMap struct {
+	mtx sync.Mutex
+	m   map[uint16]*keysMap
+}
+
+func newSlotKeyMap() *slotKeyMap {
+	return &slotKeyMap{
+		m: make(map[uint16]*keysMap),
+	}
+}
+
+// adds key to the given slot
+func (skm *slotKeyMap) add(key string, slot uint16) {
+	skm.mtx.Lock()
+	defer skm.mtx.Unlock()
+
+	km, ok := skm.m[slot]
+	if !ok {
+		km = newKeysMap()
+		skm.m[slot] = km
+	}
+	km.add(key)
+}
+
+func (skm *slotKeyMap) del(key string, slot uint16) {
+	skm.mtx.Lock()
+	defer skm.mtx.Unlock()
+
+	km, ok := skm.m[slot]
+	if !ok {
+		return
+	}
+	km.del(key)
+	if len(km.m) == 0 {
+		delete(skm.m, slot)
+	}
+}
+
+// cleanAll cleans slot<->key map of all slots
+func (skm *slotKeyMap) cleanAll() {
+	skm.mtx.Lock()
+	defer skm.mtx.Unlock()
+
+	skm.m = make(map[uint16]*keysMap)
+}
+
+// keys returns all keys which slot is inside the given slot ranges
+func (skm *slotKeyMap) keys(slots []cluster.SlotRange) []string {
+	skm.mtx.Lock()
+	defer skm.mtx.Unlock()
+
+	var keys []string
+	for slot, km := range skm.m {
+		for _, sr := range slots {
+			if !sr.Contains(slot) {
+				continue
+			}
+			for key := range km.m {
+				keys = append(keys, key)
+			}
+			break
+		}
+	}
+	return keys
+}
@@ -7,6 +7,7 @@ import (
 // StringResult represents result of the strings cache read operation
 type StringResult struct {
 	val            interface{}
+	decoded        interface{}
 	fromLocalCache bool
 }
 
@@ -17,6 +18,14 @@ func newStringResult(val interface{}, fromLocalCache bool) *StringResult {
 	}
 }
 
+func newDecodedResult(val, decoded interface{}, fromLocalCache bool) *StringResult {
+	return &StringResult{
+		val:            val,
+		decoded:        decoded,
+		fromLocalCache: fromLocalCache,
+	}
+}
+
 // Bool returns boolean representation of the result
 func (sr *StringResult) Bool() (bool, error) {
 	return redis.Bool(sr.val, nil)
@@ -27,8 +36,55 @@ func (sr *StringResult) String() (string, error) {
 	return redis.String(sr.val, nil)
 }
 
+// Int64 returns int64 representation of the result
+func (sr *StringResult) Int64() (int64, error) {
+	return redis.Int64(sr.val, nil)
+}
+
+// Float64 returns float64 representation of the result
+func (sr *StringResult) Float64() (float64, error) {
+	return redis.Float64(sr.val, nil)
+}
+
+// Bytes returns []byte representation of the result
+func (sr *StringResult) Bytes() ([]byte, error) {
+	return redis.Bytes(sr.val, nil)
+}
+
+// IsNil returns true if the result has no value
+func (sr *StringResult) IsNil() bool {
+	return sr.val == nil
+}
+
+// Scan copies the result to the value pointed by dest
+func (sr *StringResult) Scan(dest interface{}) error {
+	_, err := redis.Scan([]interface{}{sr.val}, dest)
+	return err
+}
+
 // FromLocalCache returns true if this result was coming from
 // the local inmemory cache
 func (sr *StringResult) FromLocalCache() bool {
 	return sr.fromLocalCache
 }
+
+// Decoded returns the decoded value of the result,
+// it is only available on the result of the GetDecoded
+func (sr *StringResult) Decoded() interface{} {
+	return sr.decoded
+}
+
+// decodedVal is the in memory cache value which stores both
+// the raw and the decoded value
+type decodedVal struct {
+	raw     interface{}
+	decoded interface{}
+}
+
+// rawVal returns the raw value of the in memory cache value
+func rawVal(val interface{}) interface{} {
+	if dv, ok := val.(decodedVal); ok {
+		return dv.raw
+	}
+	return val
+}
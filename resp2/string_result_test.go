@@ -0,0 +1,30 @@
+package resp2
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestStringResult_Accessors(t *testing.T) {
+	sr := newStringResult([]byte("10"), false)
+
+	i, err := sr.Int64()
+	require.NoError(t, err)
+	require.Equal(t, int64(10), i)
+
+	f, err := sr.Float64()
+	require.NoError(t, err)
+	require.Equal(t, float64(10), f)
+
+	b, err := sr.Bytes()
+	require.NoError(t, err)
+	require.Equal(t, []byte("10"), b)
+
+	var dest int
+	require.NoError(t, sr.Scan(&dest))
+	require.Equal(t, 10, dest)
+
+	require.False(t, sr.IsNil())
+	require.True(t, newStringResult(nil, false).IsNil())
+}
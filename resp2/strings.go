@@ -1,20 +1,53 @@
 package resp2
 
 import (
+	"bytes"
 	"context"
 	"errors"
+	"fmt"
 	"log"
+	"strings"
+	"sync"
+	"time"
 
 	"github.com/iwanbk/rimcu/result"
+	"github.com/rs/xid"
 
 	"github.com/iwanbk/rimcu/internal/cluster"
+	"github.com/iwanbk/rimcu/internal/compress"
+	"github.com/iwanbk/rimcu/internal/encrypt"
+	"github.com/iwanbk/rimcu/internal/invalidation"
+	"github.com/iwanbk/rimcu/internal/luascript"
+	"github.com/iwanbk/rimcu/internal/notif"
+	"github.com/iwanbk/rimcu/internal/pending"
 	"github.com/iwanbk/rimcu/internal/redigo/redis"
+	"github.com/iwanbk/rimcu/internal/redisarg"
+	"github.com/iwanbk/rimcu/internal/strrange"
+	"github.com/iwanbk/rimcu/internal/watch"
 	"github.com/iwanbk/rimcu/logger"
 )
 
 var (
 	// ErrNotFound returned when the given key is not exist
 	ErrNotFound = errors.New("not found")
+
+	// ErrInvalidArgs returned when the user pass invalid arguments to the func
+	ErrInvalidArgs = errors.New("invalid arguments")
+
+	// ErrNotSupported returned when the command can't be used
+	// because the values are compressed or encrypted
+	ErrNotSupported = errors.New("not supported on compressed or encrypted values")
+
+	errSubscriberNotConnected = errors.New("notification subscriber is not connected")
+
+	errReplicaNotFound = errors.New("replica not found")
+)
+
+var casScript = redis.NewScript(1, luascript.CompareAndSwap)
+
+const (
+	defaultClusterRefreshInterval = time.Minute
+	defaultPubSubChannel          = "rimcu:invalidate"
 )
 
 // StringsCache represents strings cache which use redis RESP2 protocol
@@ -25,6 +58,62 @@ type StringsCache struct {
 	notifSubscriber *notifSubcriber
 	logger          logger.Logger
 	mode            Mode
+	serverAddr      string
+
+	// cluster topology, only being used in ModeClusterProxy.
+	// it is guarded by trackMtx
+	clusterInfo cluster.ClusterInfo
+	explorer    *cluster.Explorer
+	refreshCh   chan struct{}
+	closeCh     chan struct{}
+	closeOnce   sync.Once
+
+	// dial options of the notification subscriber
+	notifDialOpts []redis.DialOption
+
+	// replicas to read from, nil if reading from replicas is not enabled
+	replicas     *replicaReader
+	replicaAddrs []string
+
+	// keys being read from the server
+	pending *pending.Reads
+
+	// ID of this cache and the channel to publish the invalidation messages,
+	// only being used in ModePubSub
+	id                  []byte
+	invalidationChannel string
+
+	// subscriber which receives invalidation messages of each connection,
+	// only used in ModeSingle
+	redirects *redirectMap
+
+	// invalidates the cache at slot granularity
+	slotInvalidation bool
+
+	// compressor of the large values, nil if the compression is disabled
+	compressor *compress.Compressor
+
+	// maximum size of the decompressed value
+	maxDecompressedSize int
+
+	// encryptor of the values, nil if the encryption is disabled
+	encryptor *encrypt.Encryptor
+
+	// caches the nonexistent keys
+	cacheNegative bool
+
+	// prefix of all keys of this cache
+	keyPrefix string
+
+	// listeners of the in memory cache invalidation
+	listeners invalidation.Listeners
+
+	// watchers of the keys
+	watches *watch.Registry
+
+	// trackMtx guards the in memory cache against the cleanup of disconnected
+	// subscriber, so we never cache a value which can't be invalidated
+	trackMtx sync.RWMutex
 }
 
 // StringsCacheConfig is config for the StringsCache
@@ -46,6 +135,72 @@ type StringsCacheConfig struct {
 	Password string
 
 	Mode Mode
+
+	// ClusterRefreshInterval is the interval of the cluster topology refresh,
+	// it is also the interval to restart the failed replica subscribers.
+	// Default is 1 minute
+	ClusterRefreshInterval time.Duration
+
+	// ReadFromReplicas routes the cache-miss reads to the replicas,
+	// it fallbacks to the master on errors.
+	//
+	// The replica might lag behind it's master, so the value written by
+	// Setex might not be immediately visible to the next Get.
+	ReadFromReplicas bool
+
+	// ReplicaAddrs is a list of replica addresses to read from in ModeSingle.
+	// In ModeClusterProxy, the replicas are discovered from the cluster topology.
+	ReplicaAddrs []string
+
+	// InvalidationChannel is the pub/sub channel of the invalidation messages,
+	// only being used in ModePubSub.
+	// Default is `rimcu:invalidate`
+	InvalidationChannel string
+
+	// SlotInvalidation groups the cached keys by their cluster hash slot
+	// and invalidates all keys of the slot when one of them is invalidated.
+	//
+	// It trades the cache hit ratio for a fixed amount of bookkeeping memory,
+	// the per key mappings are not needed.
+	// In ModeSingle, the connections are not tracked individually anymore,
+	// the subscriber uses broadcasting tracking instead.
+	SlotInvalidation bool
+
+	// CompressThreshold is the minimum size of the value to be compressed
+	// before being sent to the server, zero disables the compression.
+	//
+	// The compressed values are always decompressed on read,
+	// regardless of this config.
+	CompressThreshold int
+
+	// MaxDecompressedSize is the maximum size of the decompressed value,
+	// reading the larger value returns an error. Default is 64MB
+	MaxDecompressedSize int
+
+	// EncryptionKeys is a map of key ID to the AES key (16, 24, or 32 bytes)
+	// to encrypt the string values using AES-GCM, empty map disables the encryption.
+	//
+	// The values are encrypted using key with the EncryptionKeyID, and decrypted
+	// using the key which ID is stored in the value, so the old keys should be kept
+	// during the key rotation until all values encrypted by them are expired.
+	EncryptionKeys  map[string][]byte
+	EncryptionKeyID string
+
+	// CacheNegative caches the nonexistent keys as the negative entries,
+	// so reading or checking the existence of them doesn't go to the server.
+	CacheNegative bool
+
+	// KeyPrefix is the prefix of all keys of this cache, the keys given to the
+	// StringsCache must already have it.
+	//
+	// The broadcasting tracking subscribers only receive the invalidation
+	// of the keys with this prefix, and the tag index keys are put under it.
+	KeyPrefix string
+
+	// Partitions splits the inmem cache by the key prefix, each partition has it's own size quota.
+	// The keys without matching partition, and the internal values like the script results,
+	// are in the default partition which size is the CacheSize.
+	Partitions []result.PartitionConfig
 }
 
 // Mode represents the mode of the cache
@@ -57,6 +212,13 @@ const (
 
 	// ModeClusterProxy is a mode for redis cluster with front proxy like predixy
 	ModeClusterProxy Mode = "cluster-proxy"
+
+	// ModePubSub is a mode for the servers without CLIENT TRACKING support.
+	//
+	// The writes published the invalidation message to a pub/sub channel
+	// which subscribed by all of the rimcu clients, so only the writes
+	// through rimcu invalidate the cache.
+	ModePubSub Mode = "pubsub"
 )
 
 // NewStringsCache creates new StringsCache object
@@ -68,13 +230,50 @@ func NewStringsCache(cfg StringsCacheConfig) (*StringsCache, error) {
 	if cfg.Mode == "" {
 		cfg.Mode = ModeSingle
 	}
+	if cfg.ClusterRefreshInterval <= 0 {
+		cfg.ClusterRefreshInterval = defaultClusterRefreshInterval
+	}
+	if cfg.InvalidationChannel == "" {
+		cfg.InvalidationChannel = defaultPubSubChannel
+	}
 
-	cfg.Logger.Debugf("cfg:%#v", cfg)
+	encryptor, err := encrypt.New(cfg.EncryptionKeys, cfg.EncryptionKeyID)
+	if err != nil {
+		return nil, err
+	}
+
+	logCfg := cfg
+	logCfg.EncryptionKeys = nil // never log the keys
+	cfg.Logger.Debugf("cfg:%#v", logCfg)
 
 	sc := &StringsCache{
-		logger: cfg.Logger,
-		cc:     newCache(cfg.CacheSize),
-		mode:   cfg.Mode,
+		logger:     cfg.Logger,
+		cc:         newCache(cfg.CacheSize, cfg.Mode == ModeClusterProxy, cfg.SlotInvalidation),
+		mode:       cfg.Mode,
+		serverAddr: cfg.ServerAddr,
+		redirects:  newRedirectMap(),
+		refreshCh:  make(chan struct{}, 1),
+		closeCh:    make(chan struct{}),
+		pending:    pending.NewReads(),
+		watches:    watch.NewRegistry(),
+		id:         xid.New().Bytes(),
+
+		invalidationChannel: cfg.InvalidationChannel,
+		slotInvalidation:    cfg.SlotInvalidation,
+		compressor:          compress.New(cfg.CompressThreshold),
+		maxDecompressedSize: cfg.MaxDecompressedSize,
+		encryptor:           encryptor,
+		cacheNegative:       cfg.CacheNegative,
+		keyPrefix:           cfg.KeyPrefix,
+	}
+
+	sc.cc.evictCb = sc.listeners.Notify
+	for _, p := range cfg.Partitions {
+		size := p.CacheSize
+		if size <= 0 {
+			size = cfg.CacheSize
+		}
+		sc.cc.AddPartition(p.Prefix, size)
 	}
 
 	// TODO: support for user supplied pool
@@ -88,55 +287,162 @@ func NewStringsCache(cfg StringsCacheConfig) (*StringsCache, error) {
 	sc.pool = pool
 
 	sc.pool.DialCb = sc.dialCb // TODO: it can't be nil
+	sc.pool.TestOnBorrow = sc.testOnBorrow
 
-	var (
-		notifPools []*redis.Pool
-		opts       = []redis.DialOption{redis.DialCloseCb(sc.redisConnCloseCb)}
-	)
 	if cfg.Password != "" {
-		opts = append(opts, redis.DialPassword(cfg.Password))
+		sc.notifDialOpts = append(sc.notifDialOpts, redis.DialPassword(cfg.Password))
 	}
 
-	notifHosts, err := getNotifHost(cfg)
+	notifHosts, err := sc.getNotifHost(cfg)
 	if err != nil {
 		return nil, err
 	}
 
-	for _, clusterNode := range notifHosts {
-		node := clusterNode
-		pool := &redis.Pool{
-			Dial: func() (redis.Conn, error) {
-				cfg.Logger.Debugf("[notif]dialing: %v", node)
-				return redis.Dial("tcp", node, opts...)
-			},
-		}
-		notifPools = append(notifPools, pool)
+	sc.notifSubscriber = sc.newNotifSubscriber()
+
+	if err := sc.notifSubscriber.run(newNotifNodes(notifHosts, sc.notifDialOpts, sc.logger)); err != nil {
+		return nil, err
 	}
 
-	sc.notifSubscriber = newNotifSubcriber(sc.handleNotif, sc.handleNotifDisconnect, sc.mode, cfg.Logger)
+	if cfg.ReadFromReplicas && cfg.Mode == ModePubSub {
+		sc.logger.Errorf("reading from replicas is not supported in %v mode", cfg.Mode)
+	} else if cfg.ReadFromReplicas {
+		sc.replicas = newReplicaReader(sc.handleNotif, sc.handleReplicaDisconnect, sc.notifDialOpts,
+			sc.mode == ModeClusterProxy, sc.keyPrefix, sc.logger)
+		sc.replicaAddrs = cfg.ReplicaAddrs
+		sc.replicas.sync(sc.getReplicaAddrs())
+	}
 
-	return sc, sc.notifSubscriber.run(notifPools)
+	if sc.mode == ModeClusterProxy || sc.replicas != nil {
+		go sc.runRefresher(cfg.ClusterRefreshInterval)
+	}
+	return sc, nil
 }
 
-func getNotifHost(cfg StringsCacheConfig) ([]string, error) {
-	if cfg.Mode == ModeSingle {
-		return []string{cfg.ServerAddr}, nil
+func (sc *StringsCache) newNotifSubscriber() *notifSubcriber {
+	switch sc.mode {
+	case ModeClusterProxy:
+		return newNotifSubcriber(sc.handleNotif, sc.handleNotifDisconnect, sc.handleNotifFlush, subscriberBcast,
+			invalidationChannel, sc.keyPrefix, sc.logger)
+	case ModePubSub:
+		return newNotifSubcriber(sc.handleNotif, sc.handleNotifDisconnect, sc.handleNotifFlush, subscriberPubSub,
+			sc.invalidationChannel, sc.keyPrefix, sc.logger)
+	default:
+		mode := subscriberTracking
+		if !sc.connTracking() {
+			mode = subscriberBcast
+		}
+		return newNotifSubcriber(sc.handleNotif, sc.handleNotifDisconnect, sc.handleNotifFlush, mode,
+			invalidationChannel, sc.keyPrefix, sc.logger)
 	}
-	return getClusterMasters(cfg.ClusterNodes, cfg.Password)
 }
 
-func getClusterMasters(seeds []string, password string) ([]string, error) {
-	ex := cluster.NewExplorer(seeds, password)
-	ci, err := ex.Discover()
+func (sc *StringsCache) getNotifHost(cfg StringsCacheConfig) ([]string, error) {
+	if cfg.Mode != ModeClusterProxy {
+		return []string{cfg.ServerAddr}, nil
+	}
+
+	sc.explorer = cluster.NewExplorer(cfg.ClusterNodes, cfg.Password, cfg.Logger)
+	ci, err := sc.explorer.Discover()
 	if err != nil {
 		return nil, err
 	}
+	sc.clusterInfo = ci
 	return ci.Masters(), nil
 }
 
+// getReplicaAddrs returns address of all the replicas to read from
+func (sc *StringsCache) getReplicaAddrs() []string {
+	if sc.mode == ModeSingle {
+		return sc.replicaAddrs
+	}
+
+	sc.trackMtx.RLock()
+	defer sc.trackMtx.RUnlock()
+	return sc.clusterInfo.Slaves()
+}
+
+// runRefresher refreshes the cluster topology and the replicas periodically
+// and when it is triggered by triggerClusterRefresh
+func (sc *StringsCache) runRefresher(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-sc.closeCh:
+			return
+		case <-ticker.C:
+		case <-sc.refreshCh:
+		}
+
+		if sc.mode == ModeClusterProxy {
+			if err := sc.refreshCluster(); err != nil {
+				sc.logger.Errorf("failed to refresh cluster topology: %v", err)
+			}
+		}
+		if sc.replicas != nil {
+			sc.replicas.sync(sc.getReplicaAddrs())
+		}
+	}
+}
+
+// triggerClusterRefresh asks the refresher to refresh the topology
+// without waiting for the next interval
+func (sc *StringsCache) triggerClusterRefresh() {
+	select {
+	case sc.refreshCh <- struct{}{}:
+	default: // refresh already pending
+	}
+}
+
+// refreshCluster discovers the cluster topology and:
+// - cleans the cached keys of the slots which moved to other master
+// - starts subscriber of the new masters and stops subscriber of the removed masters
+func (sc *StringsCache) refreshCluster() error {
+	ci, err := sc.explorer.Discover()
+	if err != nil {
+		return err
+	}
+
+	sc.trackMtx.Lock()
+	old := sc.clusterInfo
+	sc.clusterInfo = ci
+	for _, shard := range old.Shards {
+		newSlots, ok := ci.SlotsOf(shard.Master.Addr)
+		if ok && cluster.SameSlots(shard.Slots, newSlots) {
+			continue
+		}
+		sc.logger.Debugf("slots of master %v changed", shard.Master.Addr)
+		sc.cc.CleanCacheForSlots(shard.Slots)
+	}
+	sc.trackMtx.Unlock()
+
+	sc.notifSubscriber.sync(newNotifNodes(ci.Masters(), sc.notifDialOpts, sc.logger))
+	return nil
+}
+
+// checkClusterError triggers cluster topology refresh if the error
+// indicates that the topology has changed
+func (sc *StringsCache) checkClusterError(err error) {
+	if err == nil {
+		return
+	}
+	if msg := err.Error(); strings.HasPrefix(msg, "MOVED ") || strings.HasPrefix(msg, "ASK ") {
+		sc.triggerClusterRefresh()
+	}
+}
+
 // Close closes the cache, release all resources
 func (sc *StringsCache) Close() error {
-	sc.pool.Close()
+	sc.closeOnce.Do(func() {
+		close(sc.closeCh)
+		sc.notifSubscriber.Close()
+		if sc.replicas != nil {
+			sc.replicas.close()
+		}
+		sc.pool.Close()
+	})
 	return nil
 }
 
@@ -146,79 +452,733 @@ func (sc *StringsCache) Close() error {
 // - invalidate inmem cache of other nodes
 // - initialize in mem cache of this node
 func (sc *StringsCache) Setex(ctx context.Context, key string, val interface{}, expSecond int) error {
-	// get conn
-	conn, err := sc.getConn(ctx)
+	val, err := sc.writeVal(val)
 	if err != nil {
 		return err
 	}
+
+	_, err = redis.String(sc.write(ctx, key, "SET", val, "EX", expSecond))
+	return err
+}
+
+// Incr increments the number stored at key by one and returns the new value.
+//
+// Calling this func will invalidate inmem cache of the key in all nodes.
+//
+// It is not supported if the compression or encryption is enabled.
+func (sc *StringsCache) Incr(ctx context.Context, key string) (int64, error) {
+	if !sc.plainVal() {
+		return 0, ErrNotSupported
+	}
+	return redis.Int64(sc.write(ctx, key, "INCR"))
+}
+
+// IncrBy increments the number stored at key by delta and returns the new value.
+//
+// It is not supported if the compression or encryption is enabled.
+func (sc *StringsCache) IncrBy(ctx context.Context, key string, delta int64) (int64, error) {
+	if !sc.plainVal() {
+		return 0, ErrNotSupported
+	}
+	return redis.Int64(sc.write(ctx, key, "INCRBY", delta))
+}
+
+// Decr decrements the number stored at key by one and returns the new value.
+//
+// It is not supported if the compression or encryption is enabled.
+func (sc *StringsCache) Decr(ctx context.Context, key string) (int64, error) {
+	if !sc.plainVal() {
+		return 0, ErrNotSupported
+	}
+	return redis.Int64(sc.write(ctx, key, "DECR"))
+}
+
+// DecrBy decrements the number stored at key by delta and returns the new value.
+//
+// It is not supported if the compression or encryption is enabled.
+func (sc *StringsCache) DecrBy(ctx context.Context, key string, delta int64) (int64, error) {
+	if !sc.plainVal() {
+		return 0, ErrNotSupported
+	}
+	return redis.Int64(sc.write(ctx, key, "DECRBY", delta))
+}
+
+// IncrByFloat increments the floating point number stored at key by delta
+// and returns the new value.
+//
+// It is not supported if the compression or encryption is enabled.
+func (sc *StringsCache) IncrByFloat(ctx context.Context, key string, delta float64) (float64, error) {
+	if !sc.plainVal() {
+		return 0, ErrNotSupported
+	}
+	return redis.Float64(sc.write(ctx, key, "INCRBYFLOAT", delta))
+}
+
+// Append appends the value to the end of the key and returns the new length.
+//
+// It is not supported if the compression or encryption is enabled.
+func (sc *StringsCache) Append(ctx context.Context, key, val string) (int64, error) {
+	if !sc.plainVal() {
+		return 0, ErrNotSupported
+	}
+	return redis.Int64(sc.write(ctx, key, "APPEND", val))
+}
+
+// SetRange overwrites part of the value of the key starting at the offset,
+// and returns the new length.
+//
+// It is not supported if the compression or encryption is enabled.
+func (sc *StringsCache) SetRange(ctx context.Context, key string, offset int64, val string) (int64, error) {
+	if !sc.plainVal() {
+		return 0, ErrNotSupported
+	}
+	return redis.Int64(sc.write(ctx, key, "SETRANGE", offset, val))
+}
+
+// GetRange returns the substring of the value of the key between the start and end offsets.
+//
+// It is served from the in memory cache if the key exists there.
+func (sc *StringsCache) GetRange(ctx context.Context, key string, start, end int64) (string, error) {
+	if !sc.plainVal() {
+		return "", ErrNotSupported
+	}
+	if val, ok := sc.getMemCache(key); ok {
+		b, err := localBytes(val)
+		if err != nil {
+			return "", err
+		}
+		return string(strrange.Range(b, start, end)), nil
+	}
+
+	conn, err := sc.getConn(ctx)
+	if err != nil {
+		return "", err
+	}
 	defer conn.Close()
 
-	_, err = redis.String(conn.Do("SET", key, val, "EX", expSecond))
+	str, err := redis.String(conn.Do("GETRANGE", key, start, end))
+	sc.checkClusterError(err)
+	return str, err
+}
+
+// StrLen returns the length of the value of the key, zero if the key doesn't exist.
+//
+// It is served from the in memory cache if the key exists there.
+func (sc *StringsCache) StrLen(ctx context.Context, key string) (int64, error) {
+	if !sc.plainVal() {
+		return 0, ErrNotSupported
+	}
+	if val, ok := sc.getMemCache(key); ok {
+		b, err := localBytes(val)
+		if err != nil {
+			return 0, err
+		}
+		return int64(len(b)), nil
+	}
+
+	conn, err := sc.getConn(ctx)
 	if err != nil {
-		return err
+		return 0, err
 	}
+	defer conn.Close()
 
-	//sc.cc.Set(key, val, conn.ClientID(), expSecond)
-	sc.cc.Del(key)
-	return nil
+	n, err := redis.Int64(conn.Do("STRLEN", key))
+	sc.checkClusterError(err)
+	return n, err
+}
+
+// localBytes returns the in memory cache value as bytes,
+// the negative entry is treated as empty value like the redis does
+func localBytes(val interface{}) ([]byte, error) {
+	val = rawVal(val)
+	if val == nil {
+		return nil, nil
+	}
+	return redis.Bytes(val, nil)
+}
+
+// plainVal returns true if the values are stored as is in the server,
+// without compression or encryption
+func (sc *StringsCache) plainVal() bool {
+	return sc.compressor == nil && sc.encryptor == nil
+}
+
+// Expire sets the expiration of the key in second.
+//
+// The in memory cache of the key is invalidated, so it never outlives the new expiration.
+// It returns false if the key doesn't exist
+func (sc *StringsCache) Expire(ctx context.Context, key string, expSecond int) (bool, error) {
+	return redis.Bool(sc.write(ctx, key, "EXPIRE", expSecond))
+}
+
+// PExpire sets the expiration of the key in millisecond.
+//
+// The in memory cache of the key is invalidated, so it never outlives the new expiration.
+// It returns false if the key doesn't exist
+func (sc *StringsCache) PExpire(ctx context.Context, key string, expMillisecond int64) (bool, error) {
+	return redis.Bool(sc.write(ctx, key, "PEXPIRE", expMillisecond))
+}
+
+// Persist removes the expiration of the key.
+//
+// It returns false if the key doesn't exist or doesn't have an expiration
+func (sc *StringsCache) Persist(ctx context.Context, key string) (bool, error) {
+	return redis.Bool(sc.write(ctx, key, "PERSIST"))
+}
+
+// TTL returns the remaining time to live of the key in second.
+//
+// It returns -1 if the key has no expiration, and -2 if the key doesn't exist
+func (sc *StringsCache) TTL(ctx context.Context, key string) (int64, error) {
+	conn, err := sc.getConn(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer conn.Close()
+
+	ttl, err := redis.Int64(conn.Do("TTL", key))
+	sc.checkClusterError(err)
+	return ttl, err
+}
+
+// GetEx gets the value of the key and sets it's expiration in second.
+//
+// The in memory cache of the key is invalidated and the value
+// is not cached, because the read was also a write.
+func (sc *StringsCache) GetEx(ctx context.Context, key string, expSecond int) (result.StringsResult, error) {
+	val, err := sc.write(ctx, key, "GETEX", "EX", expSecond)
+	if err != nil {
+		return newStringResult(nil, false), err
+	}
+	if val == nil {
+		return newStringResult(nil, false), ErrNotFound
+	}
+
+	val, err = sc.readVal(val, nil)
+	if err != nil {
+		return newStringResult(nil, false), err
+	}
+	return newStringResult(val, false), nil
+}
+
+// SetNX sets the key to hold the value with the given expiration only if
+// the key doesn't exist.
+//
+// It returns true if the key was set
+func (sc *StringsCache) SetNX(ctx context.Context, key string, val interface{}, expSecond int) (bool, error) {
+	return sc.setCond(ctx, key, val, expSecond, "NX")
+}
+
+// SetXX sets the key to hold the value with the given expiration only if
+// the key already exists.
+//
+// It returns true if the key was set
+func (sc *StringsCache) SetXX(ctx context.Context, key string, val interface{}, expSecond int) (bool, error) {
+	return sc.setCond(ctx, key, val, expSecond, "XX")
+}
+
+func (sc *StringsCache) setCond(ctx context.Context, key string, val interface{}, expSecond int, cond string) (bool, error) {
+	val, err := sc.writeVal(val)
+	if err != nil {
+		return false, err
+	}
+
+	reply, err := sc.writeCond(ctx, key, func(conn redis.Conn) (interface{}, error) {
+		return conn.Do("SET", key, val, "EX", expSecond, cond)
+	}, func(reply interface{}) bool {
+		return reply != nil
+	})
+	if err != nil {
+		return false, err
+	}
+	return reply != nil, nil
+}
+
+// GetSet sets the key to hold the value with the given expiration
+// and returns the old value.
+//
+// The result is nil if the key didn't exist
+func (sc *StringsCache) GetSet(ctx context.Context, key string, val interface{}, expSecond int) (result.StringsResult, error) {
+	val, err := sc.writeVal(val)
+	if err != nil {
+		return nil, err
+	}
+
+	old, err := sc.write(ctx, key, "SET", val, "EX", expSecond, "GET")
+	if err != nil {
+		return nil, err
+	}
+	if old != nil {
+		old, err = sc.readVal(old, nil)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return newStringResult(old, false), nil
+}
+
+// CompareAndSwap sets the key to hold the new value with the given expiration,
+// only if the current value equals to the old value.
+//
+// It returns true if the value was swapped
+func (sc *StringsCache) CompareAndSwap(ctx context.Context, key string, oldVal, newVal interface{},
+	expSecond int) (bool, error) {
+	newVal, err := sc.writeVal(newVal)
+	if err != nil {
+		return false, err
+	}
+
+	reply, err := sc.writeCond(ctx, key, func(conn redis.Conn) (interface{}, error) {
+		expected, ok, err := sc.storedVal(conn, key, oldVal)
+		if err != nil || !ok {
+			return int64(0), err
+		}
+		return casScript.Do(conn, key, expected, newVal, expSecond)
+	}, func(reply interface{}) bool {
+		return reply == int64(1)
+	})
+	return redis.Bool(reply, err)
+}
+
+// storedVal returns the value as it is stored in the server.
+//
+// The values are compressed or encrypted before being stored, so the stored value
+// is read from the server and compared with the given value.
+// It returns false if the stored value is not equal to the given value
+func (sc *StringsCache) storedVal(conn redis.Conn, key string, val interface{}) (interface{}, bool, error) {
+	if sc.plainVal() {
+		return val, true, nil
+	}
+
+	stored, err := conn.Do("GET", key)
+	if err != nil || stored == nil {
+		return nil, false, err
+	}
+	decoded, err := redis.Bytes(sc.readVal(stored, nil))
+	if err != nil {
+		return nil, false, err
+	}
+	return stored, bytes.Equal(decoded, redisarg.Format(val)), nil
+}
+
+// write executes the write command of the key.
+//
+// It invalidates the in memory cache of the key on success
+func (sc *StringsCache) write(ctx context.Context, key, cmd string, args ...interface{}) (interface{}, error) {
+	return sc.writeFn(ctx, key, func(conn redis.Conn) (interface{}, error) {
+		return conn.Do(cmd, append([]interface{}{key}, args...)...)
+	})
+}
+
+// writeFn executes the write func of the key using a connection from the pool.
+//
+// It invalidates the in memory cache of the key on success
+func (sc *StringsCache) writeFn(ctx context.Context, key string, fn func(conn redis.Conn) (interface{}, error)) (interface{}, error) {
+	return sc.writeKeys(ctx, []string{key}, fn)
+}
+
+// writeCond executes the conditional write func of the key using a connection from the pool.
+//
+// It invalidates the in memory cache of the key only if the applied func
+// reports that the write was applied
+func (sc *StringsCache) writeCond(ctx context.Context, key string, fn func(conn redis.Conn) (interface{}, error),
+	applied func(reply interface{}) bool) (interface{}, error) {
+	return sc.writeKeysCond(ctx, []string{key}, fn, applied)
+}
+
+// writeKeys executes the write func of the keys using a connection from the pool.
+//
+// It invalidates the in memory cache of all the keys on success
+func (sc *StringsCache) writeKeys(ctx context.Context, keys []string,
+	fn func(conn redis.Conn) (interface{}, error)) (interface{}, error) {
+	return sc.writeKeysCond(ctx, keys, fn, nil)
+}
+
+// writeKeysCond executes the write func of the keys using a connection from the pool.
+//
+// It invalidates the in memory cache of all the keys on success,
+// unless the applied func is not nil and reports that the write was not applied
+func (sc *StringsCache) writeKeysCond(ctx context.Context, keys []string,
+	fn func(conn redis.Conn) (interface{}, error), applied func(reply interface{}) bool) (interface{}, error) {
+	conn, err := sc.getConn(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	reply, err := fn(conn)
+	if err != nil {
+		sc.checkClusterError(err)
+		return nil, err
+	}
+	if applied != nil && !applied(reply) {
+		return reply, nil
+	}
+
+	for _, key := range keys {
+		sc.cc.Del(key)
+	}
+	for _, key := range keys {
+		if err := sc.publishInvalidation(conn, key); err != nil {
+			return reply, err
+		}
+	}
+	return reply, nil
 }
 
 // Get gets the value of the key.
 //
 // If the value not exists in the memory cache, it will try to get from the redis server
-// and set the expiration to the given expSecond
+// and set the expiration to the given expSecond.
+//
+// The nonexistent key returns ErrNotFound only if the CacheNegative is enabled,
+// otherwise it returns the nil result
 func (sc *StringsCache) Get(ctx context.Context, key string, expSecond int) (result.StringsResult, error) {
+	val, fromLocalCache, err := sc.get(ctx, key, expSecond, nil)
+	if err == nil && val == nil && sc.cacheNegative {
+		err = ErrNotFound
+	}
+	return newStringResult(rawVal(val), fromLocalCache), err
+}
+
+// GetDecoded gets the value of the key and decodes it using the given decoder.
+//
+// The decoded value is stored in the memory cache, so the cache hit
+// doesn't need to decode the value again.
+func (sc *StringsCache) GetDecoded(ctx context.Context, key string, expSecond int,
+	decode result.Decoder) (result.DecodedResult, error) {
+	val, fromLocalCache, err := sc.get(ctx, key, expSecond, decode)
+	if err != nil {
+		return newDecodedResult(rawVal(val), nil, fromLocalCache), err
+	}
+	if val == nil {
+		return newDecodedResult(nil, nil, fromLocalCache), ErrNotFound
+	}
+
+	dv, ok := val.(decodedVal)
+	if !ok {
+		// cached by Get, decode it without storing in the memory cache
+		dv, err = decodeVal(val, decode)
+		if err != nil {
+			return newDecodedResult(val, nil, fromLocalCache), err
+		}
+	}
+	return newDecodedResult(dv.raw, dv.decoded, fromLocalCache), nil
+}
+
+// get the value of the key and the flag whether it comes from the memory cache.
+//
+// The value read from the server is decoded using the given decoder
+// before being stored in the memory cache, if the decoder is not nil.
+func (sc *StringsCache) get(ctx context.Context, key string, expSecond int,
+	decode result.Decoder) (interface{}, bool, error) {
 	// try to get from in memory cache
 	val, ok := sc.getMemCache(key)
 	if ok {
 		sc.logger.Debugf("GET: already in memcache")
-		return newStringResult(val, true), nil
+		return val, true, nil
+	}
+
+	// get from replica
+	if val, ok, err := sc.getFromReplica(ctx, key, expSecond, decode); ok {
+		return val, false, err
 	}
 
 	// get from redis
 	conn, err := sc.getConn(ctx)
 	if err != nil {
 		log.Printf("failed to get conn:%v", err)
-		return newStringResult(nil, false), err
+		return nil, false, err
 	}
 	defer conn.Close()
 
+	sc.pending.Start(key)
 	val, err = conn.Do("GET", key)
+	if err == nil && val != nil {
+		val, err = sc.readVal(val, decode)
+	}
+	sc.pending.Finish(key, func() {
+		if err == nil && (val != nil || sc.cacheNegative) {
+			// set to in-mem cache, nil value is cached as the negative entry
+			sc.setMemCache(key, val, conn.ClientID(), expSecond)
+		}
+	})
+
+	sc.checkClusterError(err)
 	if err != nil || val == nil {
 		sc.logger.Debugf("GET val:%v, err: %v", val, err)
 		if err == redis.ErrNil {
 			err = ErrNotFound
 		}
-		return newStringResult(val, false), err
+		return val, false, err
 	}
 
-	// set to in-mem cache
-	sc.cc.Set(key, val, conn.ClientID(), expSecond)
+	return val, false, nil
+}
 
-	return newStringResult(val, false), nil
+// writeVal compresses and then encrypts the value to be sent to the server
+func (sc *StringsCache) writeVal(val interface{}) (interface{}, error) {
+	val, err := sc.compressor.Compress(val)
+	if err != nil {
+		return nil, err
+	}
+	return sc.encryptor.Encrypt(val)
 }
 
-// Del deletes the key in both memory cache and redis server
-func (sc *StringsCache) Del(ctx context.Context, key string) error {
-	sc.cc.Del(key)
+// readVal decrypts and decompresses the value read from the server,
+// and then decodes it if the decoder is not nil
+func (sc *StringsCache) readVal(val interface{}, decode result.Decoder) (interface{}, error) {
+	if data, ok := val.([]byte); ok {
+		data, err := sc.encryptor.Decrypt(data)
+		if err != nil {
+			return nil, err
+		}
+		val, err = compress.Decompress(data, sc.maxDecompressedSize)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if decode == nil {
+		return val, nil
+	}
+	return decodeVal(val, decode)
+}
+
+// decodeVal decodes the raw value read from the server
+func decodeVal(val interface{}, decode result.Decoder) (decodedVal, error) {
+	raw, err := redis.Bytes(val, nil)
+	if err != nil {
+		return decodedVal{}, err
+	}
+	decoded, err := decode(raw)
+	if err != nil {
+		return decodedVal{}, err
+	}
+	return decodedVal{
+		raw:     val,
+		decoded: decoded,
+	}, nil
+}
+
+// Del deletes the keys in both memory cache and redis server
+func (sc *StringsCache) Del(ctx context.Context, keys ...string) error {
+	return sc.del(ctx, "DEL", keys)
+}
+
+// Unlink deletes the keys in both memory cache and redis server,
+// the memory of the values is reclaimed by the server in the background
+func (sc *StringsCache) Unlink(ctx context.Context, keys ...string) error {
+	return sc.del(ctx, "UNLINK", keys)
+}
+
+func (sc *StringsCache) del(ctx context.Context, cmd string, keys []string) error {
+	if len(keys) == 0 {
+		return nil
+	}
+
+	_, err := sc.writeKeys(ctx, keys, func(conn redis.Conn) (interface{}, error) {
+		return conn.Do(cmd, redis.Args{}.AddFlat(keys)...)
+	})
+	return err
+}
+
+// Exists returns the number of the given keys which exist.
+//
+// The keys which exist in the memory cache, including the cached nonexistent keys,
+// are not sent to the redis server
+func (sc *StringsCache) Exists(ctx context.Context, keys ...string) (int64, error) {
+	var (
+		count int64
+		args  redis.Args // keys to check in the server
+	)
+	for _, key := range keys {
+		val, ok := sc.getMemCache(key)
+		if !ok {
+			args = args.Add(key)
+			continue
+		}
+		if val != nil {
+			count++
+		}
+	}
+	if len(args) == 0 {
+		return count, nil
+	}
 
-	// get from redis
 	conn, err := sc.getConn(ctx)
 	if err != nil {
-		return err
+		return 0, err
 	}
 	defer conn.Close()
 
-	_, err = conn.Do("DEL", key)
+	n, err := redis.Int64(conn.Do("EXISTS", args...))
+	if err != nil {
+		sc.checkClusterError(err)
+		return 0, err
+	}
+	return count + n, nil
+}
+
+// publishInvalidation publishes invalidation message of the key
+// to all of the rimcu clients, only being used in ModePubSub
+func (sc *StringsCache) publishInvalidation(conn redis.Conn, key string) error {
+	if sc.mode != ModePubSub {
+		return nil
+	}
+
+	n := notif.Notif{
+		ClientID: sc.id,
+		Key:      key,
+		Slot:     uint64(cluster.Slot(key)),
+	}
+	payload, err := n.Encode()
+	if err != nil {
+		return err
+	}
+
+	_, err = conn.Do("PUBLISH", sc.invalidationChannel, payload)
 	return err
 }
 
+// getFromReplica gets the value of the key from one of the replicas.
+//
+// It returns false if the value should be read from the master, either because
+// reading from replicas is not enabled, there is no replica available, or
+// the replica returns error.
+//
+// The decoding error of the value is returned with the true flag
+func (sc *StringsCache) getFromReplica(ctx context.Context, key string, expSecond int,
+	decode result.Decoder) (interface{}, bool, error) {
+	if sc.replicas == nil {
+		return nil, false, nil
+	}
+
+	addr, ok := sc.replicas.pick(sc.getKeyReplicas(key))
+	if !ok {
+		return nil, false, nil
+	}
+
+	sc.pending.Start(key)
+	val, err := sc.replicas.get(ctx, addr, key)
+	var decodeErr error
+	if err == nil && val != nil {
+		val, decodeErr = sc.readVal(val, decode)
+	}
+	sc.pending.Finish(key, func() {
+		if err != nil || decodeErr != nil || (val == nil && !sc.cacheNegative) {
+			return
+		}
+		sc.trackMtx.RLock()
+		defer sc.trackMtx.RUnlock()
+		if sc.replicas.isTracked(addr) {
+			sc.cc.SetFromNode(key, val, addr, expSecond)
+		}
+	})
+	if err != nil {
+		sc.logger.Errorf("failed to get %v from replica %v, fallback to master: %v", key, addr, err)
+		return nil, false, nil
+	}
+	return val, true, decodeErr
+}
+
+// getKeyReplicas returns the replicas which could serve the given key
+func (sc *StringsCache) getKeyReplicas(key string) []string {
+	if sc.mode == ModeSingle {
+		return sc.replicaAddrs
+	}
+
+	sc.trackMtx.RLock()
+	defer sc.trackMtx.RUnlock()
+
+	slot := cluster.Slot(key)
+	for _, shard := range sc.clusterInfo.Shards {
+		if !shard.HasSlot(slot) {
+			continue
+		}
+		addrs := make([]string, 0, len(shard.Slaves))
+		for _, slave := range shard.Slaves {
+			addrs = append(addrs, slave.Addr)
+		}
+		return addrs
+	}
+	return nil
+}
+
 func (sc *StringsCache) getMemCache(key string) (interface{}, bool) {
 	return sc.cc.Get(key)
 }
 
+// setMemCache sets the in memory cache of the value read by the given connection.
+//
+// The value is not cached if the invalidation messages of the connection
+// are redirected to the subscriber which is not connected anymore
+func (sc *StringsCache) setMemCache(key string, val interface{}, clientID int64, expSecond int) {
+	sc.setMemCacheWithDeps(key, val, clientID, expSecond, nil)
+}
+
+// setMemCacheWithDeps sets the in memory cache of the value which depends on the given keys,
+// the value is tracked by the dep keys if they are not empty
+func (sc *StringsCache) setMemCacheWithDeps(key string, val interface{}, clientID int64, expSecond int, deps []string) {
+	sc.trackMtx.RLock()
+	defer sc.trackMtx.RUnlock()
+
+	trackedKeys := []string{key}
+	if len(deps) > 0 {
+		trackedKeys = deps
+	}
+
+	if sc.mode == ModeClusterProxy {
+		for _, trackedKey := range trackedKeys {
+			if !sc.isKeyTracked(trackedKey) {
+				sc.logger.Debugf("skip caching %v: subscriber of the %v's master is not connected", key, trackedKey)
+				return
+			}
+		}
+		sc.cc.SetWithDeps(key, val, 0, expSecond, deps)
+		return
+	}
+
+	if !sc.connTracking() {
+		if sc.notifSubscriber.getClientID(sc.serverAddr) == 0 {
+			sc.logger.Debugf("skip caching %v: subscriber is not connected", key)
+			return
+		}
+		sc.cc.SetWithDeps(key, val, 0, expSecond, deps)
+		return
+	}
+
+	if !sc.isTracked(clientID) {
+		sc.logger.Debugf("skip caching %v: connection %v is not tracked", key, clientID)
+		return
+	}
+	sc.cc.SetWithDeps(key, val, clientID, expSecond, deps)
+}
+
+// isTracked returns true if the invalidation messages of the given connection
+// are received by the currently connected subscriber
+func (sc *StringsCache) isTracked(clientID int64) bool {
+	subscriberID, ok := sc.redirects.get(clientID)
+	return ok && subscriberID != 0 && subscriberID == sc.notifSubscriber.getClientID(sc.serverAddr)
+}
+
+// isKeyTracked returns true if the subscriber of the master which serves the key
+// is connected.
+//
+// It is only being used in cluster-proxy mode, where each master broadcasts
+// invalidation messages of it's own slots
+func (sc *StringsCache) isKeyTracked(key string) bool {
+	addr, ok := sc.clusterInfo.MasterOf(cluster.Slot(key))
+	return ok && sc.notifSubscriber.getClientID(addr) != 0
+}
+
+// connTracking returns true if the keys are tracked per connection,
+// which redirect it's invalidation messages to the subscriber
+func (sc *StringsCache) connTracking() bool {
+	return sc.mode == ModeSingle && !sc.slotInvalidation
+}
+
 func (sc *StringsCache) getConn(ctx context.Context) (*redis.ActiveConn, error) {
-	if sc.mode == ModeSingle {
+	if sc.connTracking() {
 		return sc.pool.GetContextWithCallback(ctx)
 	}
 	return sc.pool.GetContext(ctx)
@@ -226,16 +1186,42 @@ func (sc *StringsCache) getConn(ctx context.Context) (*redis.ActiveConn, error)
 }
 
 func (sc *StringsCache) dialCb(ctx context.Context, conn redis.Conn) error {
-	if sc.mode == ModeClusterProxy {
+	if !sc.connTracking() {
 		return nil
 	}
-	_, err := conn.Do("CLIENT", "TRACKING", "on", "REDIRECT", sc.notifSubscriber.clientID)
 
+	subscriberID := sc.notifSubscriber.getClientID(sc.serverAddr)
+	if subscriberID == 0 {
+		return errSubscriberNotConnected
+	}
+
+	_, err := conn.Do("CLIENT", "TRACKING", "on", "REDIRECT", subscriberID)
 	if err != nil {
 		sc.logger.Errorf("dial CB failed: %v", err)
+		return err
 	}
 
-	return err
+	if cidConn, ok := conn.(interface{ ClientID() int64 }); ok {
+		sc.redirects.set(cidConn.ClientID(), subscriberID)
+	}
+	return nil
+}
+
+// testOnBorrow rejects idle connection which redirects it's invalidation messages
+// to the subscriber which is not connected anymore.
+func (sc *StringsCache) testOnBorrow(conn redis.Conn, _ time.Time) error {
+	if !sc.connTracking() {
+		return nil
+	}
+
+	cidConn, ok := conn.(interface{ ClientID() int64 })
+	if !ok {
+		return nil
+	}
+	if !sc.isTracked(cidConn.ClientID()) {
+		return fmt.Errorf("connection %v has stale tracking redirection", cidConn.ClientID())
+	}
+	return nil
 }
 
 // redisConnCloseCb is callback to be called when the underlying redis connection
@@ -243,16 +1229,102 @@ func (sc *StringsCache) dialCb(ctx context.Context, conn redis.Conn) error {
 //
 // it deletes all keys belong to the given client
 func (sc *StringsCache) redisConnCloseCb(clientID int64) {
+	if clientID == 0 || !sc.connTracking() {
+		// the connection doesn't track any key
+		return
+	}
 	sc.cc.CleanCacheForConn(clientID)
+	sc.redirects.del(clientID)
+
+	// the watched keys are not tracked anymore, they might be changed
+	if keys := sc.watches.TakeTracked(clientID); len(keys) > 0 {
+		for _, key := range keys {
+			sc.watches.Notify(key)
+		}
+		go sc.retrack(keys)
+	}
 }
 
-// handle notif subscriber disconnected event
-func (sc *StringsCache) handleNotifDisconnect() {
-	sc.cc.Clear() // TODO : find other ways than complete clear like this
+// handle notif subscriber disconnected event.
+//
+// it deletes all keys which tracked by the disconnected subscriber
+func (sc *StringsCache) handleNotifDisconnect(addr string, subscriberID int64) {
+	sc.cleanSubscriberCache(addr, subscriberID)
+	sc.listeners.Notify("", result.InvalidationReconnect)
+	sc.notifyAllWatched()
+}
+
+// handle the flush invalidation message received by the subscriber.
+//
+// it deletes all keys which tracked by the subscriber
+func (sc *StringsCache) handleNotifFlush(addr string, subscriberID int64) {
+	sc.cleanSubscriberCache(addr, subscriberID)
+	sc.listeners.Notify("", result.InvalidationFlush)
+	sc.notifyAllWatched()
+}
+
+// cleanSubscriberCache deletes all keys which tracked by the subscriber:
+// - single mode: keys read by the connections which redirect to the subscriber
+// - cluster-proxy mode: keys which hashed to the slots of the subscriber's master
+// - pubsub mode & single mode with slot invalidation: all keys
+func (sc *StringsCache) cleanSubscriberCache(addr string, subscriberID int64) {
+	sc.trackMtx.Lock()
+	defer sc.trackMtx.Unlock()
+
+	if sc.mode == ModeClusterProxy {
+		slots, ok := sc.clusterInfo.SlotsOf(addr)
+		if !ok {
+			sc.logger.Errorf("unknown slots of master %v, clear all cache", addr)
+			sc.cc.Clear()
+			sc.triggerClusterRefresh()
+			return
+		}
+		sc.cc.CleanCacheForSlots(slots)
+
+		// the master might be failed over
+		sc.triggerClusterRefresh()
+		return
+	}
+
+	if !sc.connTracking() {
+		sc.cc.Clear()
+		return
+	}
+
+	for _, clientID := range sc.redirects.conns(subscriberID) {
+		sc.cc.CleanCacheForConn(clientID)
+	}
+}
+
+// handle replica's notif subscriber disconnected event.
+//
+// it deletes all keys which read from the replica
+func (sc *StringsCache) handleReplicaDisconnect(addr string, subscriberID int64) {
+	sc.trackMtx.Lock()
+	sc.cc.CleanCacheForNode(addr)
+	sc.trackMtx.Unlock()
+
+	sc.listeners.Notify("", result.InvalidationReconnect)
 }
 
 // handleNotif handle raw notification from the redis
 func (sc *StringsCache) handleNotif(key string) {
 	sc.logger.Debugf("[rimcu]got notif: %v", key)
-	sc.cc.Del(key)
+	sc.pending.Invalidate(key, func() {
+		sc.cc.Invalidate(key)
+	})
+	sc.listeners.Notify(key, result.InvalidationServer)
+	sc.notifyWatched(key)
+}
+
+// OnInvalidate registers the func to be called when the key is removed from the in memory cache.
+//
+// The func is called synchronously, it must not block or call the StringsCache
+func (sc *StringsCache) OnInvalidate(fn result.InvalidateFunc) {
+	sc.listeners.Add(fn)
+}
+
+// Stats returns the stats of the inmem cache partition which hosts the keys with the given prefix
+func (sc *StringsCache) Stats(prefix string) result.CacheStats {
+	return sc.cc.Stats(prefix)
 }
@@ -0,0 +1,94 @@
+package resp2
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/iwanbk/rimcu/internal/redigo/redis"
+)
+
+const (
+	// tagKeyPrefix is the prefix of the redis sets which index the keys of the tags
+	tagKeyPrefix = "rimcu:tag:"
+
+	// tagScanCount is the number of the tagged keys invalidated at once
+	tagScanCount = 1000
+)
+
+// SetexTags sets the key to hold the value with the given expiration second,
+// and attaches the tags to the key.
+//
+// The tag index is stored in the redis sets, so the tagged keys could be invalidated
+// by all nodes. The tag index is only cleaned by the InvalidateTag.
+func (sc *StringsCache) SetexTags(ctx context.Context, key string, val interface{}, expSecond int, tags ...string) error {
+	val, err := sc.writeVal(val)
+	if err != nil {
+		return err
+	}
+	return sc.multi(ctx, func(tx *strTx) {
+		tx.queue([]string{key}, "SET", key, val, "EX", expSecond)
+		for _, tag := range tags {
+			tx.queue(nil, "SADD", sc.tagKey(tag), key)
+		}
+	})
+}
+
+// InvalidateTag deletes all keys which have the given tag,
+// in both memory cache and redis server.
+//
+// The keys are deleted in batches, the keys tagged during
+// the invalidation might not be deleted
+func (sc *StringsCache) InvalidateTag(ctx context.Context, tag string) error {
+	var (
+		tk     = sc.tagKey(tag)
+		cursor int64
+	)
+	for {
+		next, keys, err := sc.scanTag(ctx, tk, cursor)
+		if err != nil {
+			return err
+		}
+		if len(keys) > 0 {
+			err = sc.multi(ctx, func(tx *strTx) {
+				tx.queue(keys, "DEL", redis.Args{}.AddFlat(keys)...)
+				tx.queue(nil, "SREM", redis.Args{tk}.AddFlat(keys)...)
+			})
+			if err != nil {
+				return err
+			}
+		}
+		if next == 0 {
+			return nil
+		}
+		cursor = next
+	}
+}
+
+// scanTag returns the next cursor and a batch of keys of the tag
+func (sc *StringsCache) scanTag(ctx context.Context, tk string, cursor int64) (int64, []string, error) {
+	conn, err := sc.getConn(ctx)
+	if err != nil {
+		return 0, nil, err
+	}
+	defer conn.Close()
+
+	reply, err := redis.Values(conn.Do("SSCAN", tk, cursor, "COUNT", tagScanCount))
+	if err != nil {
+		sc.checkClusterError(err)
+		return 0, nil, err
+	}
+	if len(reply) != 2 {
+		return 0, nil, fmt.Errorf("unexpected SSCAN reply: %v", reply)
+	}
+	next, err := redis.Int64(reply[0], nil)
+	if err != nil {
+		return 0, nil, err
+	}
+	keys, err := redis.Strings(reply[1], nil)
+	return next, keys, err
+}
+
+// tagKey returns the key of the tag index, it is put under the key prefix
+func (sc *StringsCache) tagKey(tag string) string {
+	return sc.keyPrefix + tagKeyPrefix + tag
+}
@@ -0,0 +1,157 @@
+package resp2
+
+import (
+	"context"
+
+	"github.com/iwanbk/rimcu/internal/redigo/redis"
+	"github.com/iwanbk/rimcu/result"
+)
+
+// strTx is the transaction of the StringsCache
+type strTx struct {
+	sc   *StringsCache
+	conn redis.Conn
+
+	cmds []txCmd
+	keys []string // written keys
+	err  error    // error of the queued writes
+}
+
+type txCmd struct {
+	name string
+	args []interface{}
+}
+
+// Tx executes the transaction func on a single connection which watches the given keys.
+//
+// The queued writes are executed using MULTI/EXEC, and the in memory cache
+// of the written keys are only invalidated after the EXEC succeed.
+// It returns result.ErrTxConflict if the watched keys were modified before the EXEC.
+func (sc *StringsCache) Tx(ctx context.Context, watchKeys []string, fn func(tx result.Tx) error) error {
+	conn, err := sc.getConn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	if len(watchKeys) > 0 {
+		if _, err = conn.Do("WATCH", redis.Args{}.AddFlat(watchKeys)...); err != nil {
+			sc.checkClusterError(err)
+			return err
+		}
+	}
+
+	tx := &strTx{
+		sc:   sc,
+		conn: conn,
+	}
+	if err = fn(tx); err == nil {
+		err = tx.err
+	}
+	if err != nil || len(tx.cmds) == 0 {
+		conn.Do("UNWATCH")
+		return err
+	}
+
+	return tx.exec()
+}
+
+// multi executes the writes queued by the fn atomically using MULTI/EXEC
+func (sc *StringsCache) multi(ctx context.Context, fn func(tx *strTx)) error {
+	conn, err := sc.getConn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	tx := &strTx{
+		sc:   sc,
+		conn: conn,
+	}
+	fn(tx)
+	if tx.err != nil || len(tx.cmds) == 0 {
+		return tx.err
+	}
+	return tx.exec()
+}
+
+// exec executes the queued writes atomically
+func (tx *strTx) exec() error {
+	conn := tx.conn
+	if err := conn.Send("MULTI"); err != nil {
+		return err
+	}
+	for _, cmd := range tx.cmds {
+		if err := conn.Send(cmd.name, cmd.args...); err != nil {
+			return err
+		}
+	}
+	replies, err := redis.Values(conn.Do("EXEC"))
+	if err != nil {
+		tx.sc.checkClusterError(err)
+		if err == redis.ErrNil {
+			return result.ErrTxConflict
+		}
+		return err
+	}
+
+	// the transaction was executed, even if some of the commands failed
+	for _, key := range tx.keys {
+		tx.sc.cc.Del(key)
+	}
+	for _, key := range tx.keys {
+		if err := tx.sc.publishInvalidation(conn, key); err != nil {
+			return err
+		}
+	}
+
+	for _, reply := range replies {
+		if err, ok := reply.(redis.Error); ok {
+			return err
+		}
+	}
+	return nil
+}
+
+// Get gets the value of the key from the server, bypassing the memory cache
+func (tx *strTx) Get(ctx context.Context, key string) (result.StringsResult, error) {
+	val, err := tx.conn.Do("GET", key)
+	if err != nil {
+		tx.sc.checkClusterError(err)
+		return newStringResult(nil, false), err
+	}
+	if val == nil {
+		return newStringResult(nil, false), ErrNotFound
+	}
+
+	val, err = tx.sc.readVal(val, nil)
+	if err != nil {
+		return newStringResult(nil, false), err
+	}
+	return newStringResult(val, false), nil
+}
+
+// Setex queues the set of the key with the given expiration second
+func (tx *strTx) Setex(key string, val interface{}, exp int) {
+	val, err := tx.sc.writeVal(val)
+	if err != nil {
+		if tx.err == nil {
+			tx.err = err
+		}
+		return
+	}
+	tx.queue([]string{key}, "SET", key, val, "EX", exp)
+}
+
+// Del queues the deletion of the keys
+func (tx *strTx) Del(keys ...string) {
+	tx.queue(keys, "DEL", redis.Args{}.AddFlat(keys)...)
+}
+
+func (tx *strTx) queue(keys []string, name string, args ...interface{}) {
+	tx.cmds = append(tx.cmds, txCmd{
+		name: name,
+		args: args,
+	})
+	tx.keys = append(tx.keys, keys...)
+}
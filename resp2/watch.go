@@ -0,0 +1,88 @@
+package resp2
+
+import (
+	"context"
+	"time"
+)
+
+const (
+	// retrackTimeout is the timeout of tracking the watched keys again
+	retrackTimeout = 5 * time.Second
+
+	// retrackDelay is the delay before retrying the failed tracking of the watched keys
+	retrackDelay = time.Second
+)
+
+// Watch watches the changes of the given keys without caching their values.
+//
+// The returned channel receives the key when the key is changed or it might be changed,
+// e.g. the invalidation messages might be lost because the subscriber was disconnected.
+// The channel is closed when the ctx is done.
+func (sc *StringsCache) Watch(ctx context.Context, keys ...string) (<-chan string, error) {
+	if len(keys) == 0 {
+		return nil, ErrInvalidArgs
+	}
+
+	// register first, so we don't miss the invalidation messages
+	ch, cancel := sc.watches.Watch(ctx, keys)
+	if err := sc.trackWatched(ctx, keys); err != nil {
+		cancel()
+		return nil, err
+	}
+	return ch, nil
+}
+
+// trackWatched makes the server track the watched keys
+func (sc *StringsCache) trackWatched(ctx context.Context, keys []string) error {
+	clientID, err := sc.trackKeys(ctx, keys)
+	if err != nil {
+		return err
+	}
+	if clientID != 0 {
+		sc.watches.SetTracker(keys, clientID)
+	}
+	return nil
+}
+
+// notifyWatched notifies the watchers of the key,
+// and makes the server track the key again
+func (sc *StringsCache) notifyWatched(key string) {
+	if sc.watches.Notify(key) && sc.connTracking() {
+		go sc.retrack([]string{key})
+	}
+}
+
+// notifyAllWatched notifies the watchers of all keys,
+// and makes the server track the keys again
+func (sc *StringsCache) notifyAllWatched() {
+	if keys := sc.watches.NotifyAll(); len(keys) > 0 && sc.connTracking() {
+		go sc.retrack(keys)
+	}
+}
+
+// retrack makes the server track the watched keys again,
+// the server only sends one invalidation message after the key is tracked.
+//
+// It retries until the keys are tracked, not watched anymore, or the cache is closed.
+func (sc *StringsCache) retrack(keys []string) {
+	for {
+		keys = sc.watches.Watched(keys)
+		if len(keys) == 0 {
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), retrackTimeout)
+		err := sc.trackWatched(ctx, keys)
+		cancel()
+		if err == nil {
+			return
+		}
+		sc.logger.Errorf("failed to track the watched keys: %v", err)
+
+		select {
+		case <-sc.closeCh:
+			return
+		case <-time.After(retrackDelay):
+		}
+	}
+}
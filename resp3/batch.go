@@ -0,0 +1,139 @@
+package resp3
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/iwanbk/resp3"
+	"github.com/iwanbk/rimcu/result"
+)
+
+// ExecBatch executes the batch operations.
+//
+// The Get of the key which exists in the memory cache, and not written by the previous
+// operations in the batch, is answered from the memory cache.
+// The rest of the operations are sent in one pipeline using a single connection.
+//
+// The result of each operation is stored in the operation, the returned error
+// is the connection error which makes the results of the pipelined operations unknown.
+func (c *Cache) ExecBatch(ctx context.Context, ops []*result.BatchOp) error {
+	var (
+		pipelined []*result.BatchOp
+		cmds      [][]interface{}
+		written   = make(map[string]struct{})
+	)
+	for _, op := range ops {
+		switch op.Type {
+		case result.BatchGet:
+			key := op.Keys[0]
+			if _, ok := written[key]; !ok {
+				if val, ok := c.memGet2(key); ok {
+					if val.val == nil {
+						op.Err = ErrNotFound
+					} else {
+						op.Res = newStringsResult(val, true)
+					}
+					continue
+				}
+			}
+			c.pending.Start(key)
+			cmds = append(cmds, []interface{}{cmdGet, key})
+
+		case result.BatchSetex:
+			val, err := c.writeVal(op.Val)
+			if err != nil {
+				op.Err = err
+				continue
+			}
+			written[op.Keys[0]] = struct{}{}
+			cmds = append(cmds, []interface{}{cmdSet, op.Keys[0], val, "EX", strconv.Itoa(op.Exp)})
+
+		case result.BatchDel:
+			cmd := []interface{}{cmdDel}
+			for _, key := range op.Keys {
+				written[key] = struct{}{}
+				c.memDel(key)
+				cmd = append(cmd, key)
+			}
+			cmds = append(cmds, cmd)
+		}
+		pipelined = append(pipelined, op)
+	}
+	if len(pipelined) == 0 {
+		return nil
+	}
+
+	conn, err := c.pool.Get(ctx)
+	if err != nil {
+		c.abortBatch(pipelined, err)
+		return err
+	}
+	defer conn.Close()
+
+	replies, err := conn.Pipeline(ctx, cmds)
+	if err != nil {
+		c.abortBatch(pipelined, err)
+		return err
+	}
+
+	for i, op := range pipelined {
+		resp := replies[i]
+		if op.Type == result.BatchGet {
+			c.finishBatchGet(op, resp)
+			continue
+		}
+		if isErrorResp(resp) {
+			op.Err = fmt.Errorf("%s", resp.Err)
+			continue
+		}
+		for _, key := range op.Keys {
+			c.memDel(key)
+		}
+	}
+	return nil
+}
+
+// finishBatchGet sets the result of the pipelined Get,
+// and caches the value in the memory cache if the key was not invalidated during the read
+func (c *Cache) finishBatchGet(op *result.BatchOp, resp *resp3.Value) {
+	var (
+		key, exp = op.Keys[0], time.Duration(op.Exp) * time.Second
+		val      cacheVal
+	)
+	switch {
+	case isErrorResp(resp):
+		op.Err = fmt.Errorf("%s", resp.Err)
+	case c.isNullString(resp):
+		op.Err = ErrNotFound
+	default:
+		str, err := c.readStr(resp.Str)
+		if err != nil {
+			op.Err = err
+			break
+		}
+		val = cacheVal{
+			typ: cacheTypString,
+			val: str,
+		}
+		op.Res = newStringsResult(val, false)
+	}
+
+	c.pending.Finish(key, func() {
+		if op.Err == nil || (op.Err == ErrNotFound && c.cacheNegative) {
+			c.memSet(key, val, exp)
+		}
+	})
+}
+
+// abortBatch sets the error of the pipelined operations,
+// and finishes the pending reads of the Get operations
+func (c *Cache) abortBatch(ops []*result.BatchOp, err error) {
+	for _, op := range ops {
+		op.Err = err
+		if op.Type == result.BatchGet {
+			c.pending.Finish(op.Keys[0], func() {})
+		}
+	}
+}
@@ -5,22 +5,41 @@ import (
 	"errors"
 	"fmt"
 	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/iwanbk/rimcu/result"
 
 	"github.com/iwanbk/resp3"
+	"github.com/iwanbk/rimcu/internal/compress"
+	"github.com/iwanbk/rimcu/internal/encrypt"
+	"github.com/iwanbk/rimcu/internal/invalidation"
+	"github.com/iwanbk/rimcu/internal/luascript"
+	"github.com/iwanbk/rimcu/internal/pending"
+	"github.com/iwanbk/rimcu/internal/redigo/redis"
+	"github.com/iwanbk/rimcu/internal/redisarg"
 	"github.com/iwanbk/rimcu/internal/resp3pool"
+	"github.com/iwanbk/rimcu/internal/strrange"
+	"github.com/iwanbk/rimcu/internal/watch"
 	"github.com/iwanbk/rimcu/logger"
-	"github.com/karlseguin/ccache"
 )
 
+// internalKeyPrefix is the prefix of the in memory cache keys of the internal values,
+// e.g. the script results, it can't be used by the redis keys
+const internalKeyPrefix = "\x00"
+
 var (
 	// ErrNotFound returned when the value of the key is not exists
 	ErrNotFound = errors.New("not found")
 
 	// ErrInvalidArgs returned when the user pass invalid arguments to the func
 	ErrInvalidArgs = errors.New("invalid arguments")
+
+	// ErrNotSupported returned when the command can't be used
+	// because the values are compressed or encrypted
+	ErrNotSupported = errors.New("not supported on compressed or encrypted values")
 )
 
 // Cache represents in memory cache which sync the cache
@@ -28,8 +47,42 @@ var (
 type Cache struct {
 	pool *resp3pool.Pool
 
-	// in memory cache
-	memcache *ccache.Cache
+	// partitions of the in memory cache, ordered by their prefix length.
+	// The last one is the default partition which prefix is empty
+	parts []*memPartition
+
+	// maps the keys to the cached values which depend on them
+	deps *depKeys
+
+	// keys being read from the server
+	pending *pending.Reads
+
+	// compressor of the large values, nil if the compression is disabled
+	compressor *compress.Compressor
+
+	// maximum size of the decompressed value
+	maxDecompressedSize int
+
+	// encryptor of the values, nil if the encryption is disabled.
+	// encryptErr is the error of the invalid encryption config,
+	// the writes are failed with this error, so we never write plain values
+	encryptor  *encrypt.Encryptor
+	encryptErr error
+
+	// caches the nonexistent keys
+	cacheNegative bool
+
+	// prefix of all keys of this cache
+	keyPrefix string
+
+	// listeners of the in memory cache invalidation
+	listeners invalidation.Listeners
+
+	// watchers of the keys
+	watches *watch.Registry
+
+	closeCh   chan struct{}
+	closeOnce sync.Once
 
 	logger logger.Logger
 }
@@ -45,11 +98,46 @@ type Config struct {
 
 	// logger to be used, use default logger which print to stderr on error
 	Logger logger.Logger
+
+	// CompressThreshold is the minimum size of the value to be compressed
+	// before being sent to the server, zero disables the compression.
+	//
+	// The compressed values are always decompressed on read,
+	// regardless of this config.
+	CompressThreshold int
+
+	// MaxDecompressedSize is the maximum size of the decompressed value,
+	// reading the larger value returns an error. Default is 64MB
+	MaxDecompressedSize int
+
+	// EncryptionKeys is a map of key ID to the AES key (16, 24, or 32 bytes)
+	// to encrypt the string values using AES-GCM, empty map disables the encryption.
+	//
+	// The values are encrypted using key with the EncryptionKeyID, and decrypted
+	// using the key which ID is stored in the value, so the old keys should be kept
+	// during the key rotation until all values encrypted by them are expired.
+	EncryptionKeys  map[string][]byte
+	EncryptionKeyID string
+
+	// CacheNegative caches the nonexistent keys as the negative entries,
+	// so reading or checking the existence of them doesn't go to the server.
+	CacheNegative bool
+
+	// KeyPrefix is the prefix of all keys of this cache, the keys given to the
+	// Cache must already have it.
+	//
+	// The tag index keys are put under it.
+	KeyPrefix string
+
+	// Partitions splits the in memory cache by the key prefix, each partition has it's own size quota.
+	// The keys without matching partition, and the internal values like the script results,
+	// are in the default partition which size is the CacheSize.
+	Partitions []result.PartitionConfig
 }
 
 // New create strings cache with redis RESP3 protocol
 func New(cfg Config) *Cache {
-	if cfg.CacheSize == 0 {
+	if cfg.CacheSize <= 0 {
 		cfg.CacheSize = 100000
 	}
 	if cfg.Logger == nil {
@@ -57,12 +145,35 @@ func New(cfg Config) *Cache {
 	}
 
 	sc := &Cache{
-		memcache: ccache.New(ccache.Configure().MaxSize(1000)),
-		logger:   cfg.Logger,
+		parts:      []*memPartition{newMemPartition("", cfg.CacheSize)},
+		deps:       newDepKeys(),
+		pending:    pending.NewReads(),
+		watches:    watch.NewRegistry(),
+		closeCh:    make(chan struct{}),
+		logger:     cfg.Logger,
+		compressor: compress.New(cfg.CompressThreshold),
+
+		maxDecompressedSize: cfg.MaxDecompressedSize,
+
+		cacheNegative: cfg.CacheNegative,
+		keyPrefix:     cfg.KeyPrefix,
+	}
+	for _, p := range cfg.Partitions {
+		size := p.CacheSize
+		if size <= 0 {
+			size = cfg.CacheSize
+		}
+		sc.addPartition(p.Prefix, size)
+	}
+	sc.encryptor, sc.encryptErr = encrypt.New(cfg.EncryptionKeys, cfg.EncryptionKeyID)
+	if sc.encryptErr != nil {
+		sc.logger.Errorf("invalid encryption config: %v", sc.encryptErr)
 	}
 	poolCfg := resp3pool.PoolConfig{
 		ServerAddr:   cfg.ServerAddr,
 		InvalidateCb: sc.invalidate,
+		ClearCb:      sc.clear,
+		FlushCb:      sc.flush,
 		Logger:       sc.logger,
 	}
 	sc.pool = resp3pool.NewPool(poolCfg)
@@ -79,6 +190,10 @@ type StringValue struct {
 //
 // Calling this func will invalidate inmem cache of this key's slot in other nodes.
 func (c *Cache) Setex(ctx context.Context, key string, val interface{}, exp int) error {
+	val, err := c.writeVal(val)
+	if err != nil {
+		return err
+	}
 	return c.write(ctx, cmdSet, key, val, "EX", strconv.Itoa(exp))
 }
 
@@ -90,32 +205,499 @@ func (c *Cache) Get(ctx context.Context, key string, exp int) (result.StringsRes
 	// get from mem, if exists
 	val, ok := c.memGet2(key)
 	if ok {
+		if val.val == nil {
+			return nil, ErrNotFound
+		}
 		return newStringsResult(val, true), nil
 	}
 
-	resp, err := c.get(ctx, cmdGet, key)
+	val, err := c.readVal(ctx, key, exp, nil)
 	if err != nil {
 		return nil, err
 	}
-	val = cacheVal{
+	return newStringsResult(val, false), nil
+}
+
+// readVal reads the value of the key from the server and caches it in the memory cache,
+// the value is decoded if the decoder is not nil.
+//
+// The nonexistent key is cached as the negative entry. The value is not cached
+// if the key was invalidated while it was being read.
+func (c *Cache) readVal(ctx context.Context, key string, exp int, decode result.Decoder) (cacheVal, error) {
+	c.pending.Start(key)
+	val, err := c.fetchVal(ctx, key, decode)
+	c.pending.Finish(key, func() {
+		if err == nil || (err == ErrNotFound && c.cacheNegative) {
+			c.memSet(key, val, time.Duration(exp)*time.Second)
+		}
+	})
+	return val, err
+}
+
+// fetchVal gets the value of the key from the server
+func (c *Cache) fetchVal(ctx context.Context, key string, decode result.Decoder) (cacheVal, error) {
+	resp, err := c.get(ctx, cmdGet, key)
+	if err != nil {
+		return cacheVal{}, err
+	}
+	str, err := c.readStr(resp.Str)
+	if err != nil {
+		return cacheVal{}, err
+	}
+	val := cacheVal{
 		typ: cacheTypString, // TODO : fix it, not all values are in string type
-		val: resp.Str,
+		val: str,
 	}
+	if decode != nil {
+		val.decoded, err = c.decode(val, decode)
+		if err != nil {
+			return cacheVal{}, err
+		}
+	}
+	return val, nil
+}
 
-	// add to in mem cache
-	c.memSet(key, val, time.Duration(exp)*time.Second)
+// GetDecoded gets the value of key and decodes it using the given decoder.
+//
+// The decoded value is stored in the memory cache, so the cache hit
+// doesn't need to decode the value again.
+func (c *Cache) GetDecoded(ctx context.Context, key string, exp int, decode result.Decoder) (result.DecodedResult, error) {
+	// get from mem, if exists
+	val, ok := c.memGet2(key)
+	if ok {
+		if val.val == nil {
+			return nil, ErrNotFound
+		}
+		if val.decoded == nil {
+			// cached by Get, decode it without storing in the memory cache
+			decoded, err := c.decode(val, decode)
+			if err != nil {
+				return nil, err
+			}
+			val.decoded = decoded
+		}
+		return newStringsResult(val, true), nil
+	}
 
+	val, err := c.readVal(ctx, key, exp, decode)
+	if err != nil {
+		return nil, err
+	}
 	return newStringsResult(val, false), nil
 }
 
-// Del deletes the key in local and remote
-func (c *Cache) Del(ctx context.Context, key string) error {
-	return c.write(ctx, cmdDel, key)
+// writeVal compresses and then encrypts the value to be sent to the server.
+//
+// The value is formatted the same way as the RESP2 engine does, so both engines
+// store the same value the same way
+func (c *Cache) writeVal(val interface{}) (interface{}, error) {
+	if c.encryptErr != nil {
+		return nil, c.encryptErr
+	}
+	val, err := c.compressor.Compress(redisarg.Format(val))
+	if err != nil {
+		return nil, err
+	}
+	return c.encryptor.Encrypt(val)
+}
+
+// readStr decrypts and decompresses the value read from the server
+func (c *Cache) readStr(str string) (string, error) {
+	if !strings.HasPrefix(str, encrypt.Header) && !strings.HasPrefix(str, compress.Header) {
+		return str, nil
+	}
+	data, err := c.encryptor.Decrypt([]byte(str))
+	if err != nil {
+		return "", err
+	}
+	data, err = compress.Decompress(data, c.maxDecompressedSize)
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
+
+func (c *Cache) decode(val cacheVal, decode result.Decoder) (interface{}, error) {
+	str, ok := val.val.(string)
+	if !ok {
+		return nil, fmt.Errorf("not a string")
+	}
+	return decode([]byte(str))
+}
+
+// Del deletes the keys in local and remote
+func (c *Cache) Del(ctx context.Context, keys ...string) error {
+	return c.del(ctx, cmdDel, keys)
+}
+
+// Unlink deletes the keys in local and remote,
+// the memory of the values is reclaimed by the server in the background
+func (c *Cache) Unlink(ctx context.Context, keys ...string) error {
+	return c.del(ctx, cmdUnlink, keys)
+}
+
+func (c *Cache) del(ctx context.Context, cmd string, keys []string) error {
+	if len(keys) == 0 {
+		return nil
+	}
+
+	args := make([]interface{}, len(keys))
+	for i, key := range keys {
+		args[i] = key
+	}
+	if _, err := c._do(ctx, cmd, args...); err != nil {
+		return err
+	}
+
+	for _, key := range keys {
+		c.memDel(key)
+	}
+	return nil
+}
+
+// Exists returns the number of the given keys which exist.
+//
+// The keys which exist in the memory cache, including the cached nonexistent keys,
+// are not sent to the redis server
+func (c *Cache) Exists(ctx context.Context, keys ...string) (int64, error) {
+	var (
+		count int64
+		args  []interface{} // keys to check in the server
+	)
+	for _, key := range keys {
+		val, ok := c.memGet2(key)
+		if !ok {
+			args = append(args, key)
+			continue
+		}
+		if val.val != nil {
+			count++
+		}
+	}
+	if len(args) == 0 {
+		return count, nil
+	}
+
+	resp, err := c._do(ctx, cmdExists, args...)
+	if err != nil {
+		return 0, err
+	}
+	return count + resp.Integer, nil
+}
+
+// Append appends the value to the end of the key and returns the new length.
+//
+// It is not supported if the compression or encryption is enabled.
+func (c *Cache) Append(ctx context.Context, key, val string) (int64, error) {
+	if !c.plainVal() {
+		return 0, ErrNotSupported
+	}
+	return c.writeInt(ctx, cmdAppend, key, val)
+}
+
+// SetRange overwrites part of the value of the key starting at the offset,
+// and returns the new length.
+//
+// It is not supported if the compression or encryption is enabled.
+func (c *Cache) SetRange(ctx context.Context, key string, offset int64, val string) (int64, error) {
+	if !c.plainVal() {
+		return 0, ErrNotSupported
+	}
+	return c.writeInt(ctx, cmdSetRange, key, offset, val)
+}
+
+// GetRange returns the substring of the value of the key between the start and end offsets.
+//
+// It is served from the in memory cache if the key exists there.
+func (c *Cache) GetRange(ctx context.Context, key string, start, end int64) (string, error) {
+	if !c.plainVal() {
+		return "", ErrNotSupported
+	}
+	if val, ok := c.memGet2(key); ok {
+		str, err := localStr(val)
+		if err != nil {
+			return "", err
+		}
+		return string(strrange.Range([]byte(str), start, end)), nil
+	}
+
+	resp, err := c.do(ctx, cmdGetRange, key, start, end)
+	if err != nil {
+		return "", err
+	}
+	return resp.Str, nil
+}
+
+// StrLen returns the length of the value of the key, zero if the key doesn't exist.
+//
+// It is served from the in memory cache if the key exists there.
+func (c *Cache) StrLen(ctx context.Context, key string) (int64, error) {
+	if !c.plainVal() {
+		return 0, ErrNotSupported
+	}
+	if val, ok := c.memGet2(key); ok {
+		str, err := localStr(val)
+		if err != nil {
+			return 0, err
+		}
+		return int64(len(str)), nil
+	}
+
+	resp, err := c.do(ctx, cmdStrLen, key)
+	if err != nil {
+		return 0, err
+	}
+	return resp.Integer, nil
+}
+
+// localStr returns the in memory cache value as string,
+// the negative entry is treated as empty string like the redis does
+func localStr(val cacheVal) (string, error) {
+	if val.val == nil {
+		return "", nil
+	}
+	str, ok := val.val.(string)
+	if !ok {
+		return "", fmt.Errorf("not a string")
+	}
+	return str, nil
+}
+
+// plainVal returns true if the values are stored as is in the server,
+// without compression or encryption
+func (c *Cache) plainVal() bool {
+	return c.compressor == nil && c.encryptor == nil && c.encryptErr == nil
+}
+
+// Incr increments the number stored at key by one and returns the new value.
+//
+// Calling this func will invalidate inmem cache of the key in other nodes.
+//
+// It is not supported if the compression or encryption is enabled.
+func (c *Cache) Incr(ctx context.Context, key string) (int64, error) {
+	if !c.plainVal() {
+		return 0, ErrNotSupported
+	}
+	return c.writeInt(ctx, cmdIncr, key)
+}
+
+// IncrBy increments the number stored at key by delta and returns the new value.
+//
+// It is not supported if the compression or encryption is enabled.
+func (c *Cache) IncrBy(ctx context.Context, key string, delta int64) (int64, error) {
+	if !c.plainVal() {
+		return 0, ErrNotSupported
+	}
+	return c.writeInt(ctx, cmdIncrBy, key, delta)
+}
+
+// Decr decrements the number stored at key by one and returns the new value.
+//
+// It is not supported if the compression or encryption is enabled.
+func (c *Cache) Decr(ctx context.Context, key string) (int64, error) {
+	if !c.plainVal() {
+		return 0, ErrNotSupported
+	}
+	return c.writeInt(ctx, cmdDecr, key)
+}
+
+// DecrBy decrements the number stored at key by delta and returns the new value.
+//
+// It is not supported if the compression or encryption is enabled.
+func (c *Cache) DecrBy(ctx context.Context, key string, delta int64) (int64, error) {
+	if !c.plainVal() {
+		return 0, ErrNotSupported
+	}
+	return c.writeInt(ctx, cmdDecrBy, key, delta)
+}
+
+// IncrByFloat increments the floating point number stored at key by delta
+// and returns the new value.
+//
+// It is not supported if the compression or encryption is enabled.
+func (c *Cache) IncrByFloat(ctx context.Context, key string, delta float64) (float64, error) {
+	if !c.plainVal() {
+		return 0, ErrNotSupported
+	}
+	resp, err := c.writeResp(ctx, cmdIncrByFloat, key, delta)
+	if err != nil {
+		return 0, err
+	}
+	if resp.Type == resp3.TypeDouble {
+		return resp.Double, nil
+	}
+	return strconv.ParseFloat(resp.Str, 64)
+}
+
+func (c *Cache) writeInt(ctx context.Context, cmd, key string, args ...interface{}) (int64, error) {
+	resp, err := c.writeResp(ctx, cmd, key, args...)
+	if err != nil {
+		return 0, err
+	}
+	if resp.Type != resp3.TypeNumber {
+		return 0, fmt.Errorf("unexpected response type: %c", resp.Type)
+	}
+	return resp.Integer, nil
+}
+
+// Expire sets the expiration of the key in second.
+//
+// The in memory cache of the key is invalidated, so it never outlives the new expiration.
+// It returns false if the key doesn't exist
+func (c *Cache) Expire(ctx context.Context, key string, exp int) (bool, error) {
+	n, err := c.writeInt(ctx, cmdExpire, key, exp)
+	return n == 1, err
+}
+
+// PExpire sets the expiration of the key in millisecond.
+//
+// The in memory cache of the key is invalidated, so it never outlives the new expiration.
+// It returns false if the key doesn't exist
+func (c *Cache) PExpire(ctx context.Context, key string, expMillisecond int64) (bool, error) {
+	n, err := c.writeInt(ctx, cmdPExpire, key, expMillisecond)
+	return n == 1, err
+}
+
+// Persist removes the expiration of the key.
+//
+// It returns false if the key doesn't exist or doesn't have an expiration
+func (c *Cache) Persist(ctx context.Context, key string) (bool, error) {
+	n, err := c.writeInt(ctx, cmdPersist, key)
+	return n == 1, err
+}
+
+// TTL returns the remaining time to live of the key in second.
+//
+// It returns -1 if the key has no expiration, and -2 if the key doesn't exist
+func (c *Cache) TTL(ctx context.Context, key string) (int64, error) {
+	resp, err := c.do(ctx, cmdTTL, key)
+	if err != nil {
+		return 0, err
+	}
+	return resp.Integer, nil
+}
+
+// GetEx gets the value of the key and sets it's expiration in second.
+//
+// The in memory cache of the key is invalidated and the value
+// is not cached, because the read was also a write.
+func (c *Cache) GetEx(ctx context.Context, key string, exp int) (result.StringsResult, error) {
+	resp, err := c.writeResp(ctx, cmdGetEx, key, "EX", strconv.Itoa(exp))
+	if err != nil {
+		return nil, err
+	}
+	if c.isNullString(resp) {
+		return nil, ErrNotFound
+	}
+
+	str, err := c.readStr(resp.Str)
+	if err != nil {
+		return nil, err
+	}
+	return newStringsResult(cacheVal{typ: cacheTypString, val: str}, false), nil
+}
+
+// SetNX sets the key to hold the value with the given expiration only if
+// the key doesn't exist.
+//
+// It returns true if the key was set
+func (c *Cache) SetNX(ctx context.Context, key string, val interface{}, exp int) (bool, error) {
+	return c.setCond(ctx, key, val, exp, "NX")
+}
+
+// SetXX sets the key to hold the value with the given expiration only if
+// the key already exists.
+//
+// It returns true if the key was set
+func (c *Cache) SetXX(ctx context.Context, key string, val interface{}, exp int) (bool, error) {
+	return c.setCond(ctx, key, val, exp, "XX")
+}
+
+func (c *Cache) setCond(ctx context.Context, key string, val interface{}, exp int, cond string) (bool, error) {
+	val, err := c.writeVal(val)
+	if err != nil {
+		return false, err
+	}
+	resp, err := c.do(ctx, cmdSet, key, val, "EX", strconv.Itoa(exp), cond)
+	if err != nil || c.isNullString(resp) {
+		return false, err
+	}
+	c.memDel(key)
+	return true, nil
+}
+
+// GetSet sets the key to hold the value with the given expiration
+// and returns the old value.
+//
+// The result is nil if the key didn't exist
+func (c *Cache) GetSet(ctx context.Context, key string, val interface{}, exp int) (result.StringsResult, error) {
+	val, err := c.writeVal(val)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := c.writeResp(ctx, cmdSet, key, val, "EX", strconv.Itoa(exp), "GET")
+	if err != nil {
+		return nil, err
+	}
+	if c.isNullString(resp) {
+		return newStringsResult(cacheVal{}, false), nil
+	}
+
+	str, err := c.readStr(resp.Str)
+	if err != nil {
+		return nil, err
+	}
+	return newStringsResult(cacheVal{typ: cacheTypString, val: str}, false), nil
+}
+
+// CompareAndSwap sets the key to hold the new value with the given expiration,
+// only if the current value equals to the old value.
+//
+// It returns true if the value was swapped
+func (c *Cache) CompareAndSwap(ctx context.Context, key string, oldVal, newVal interface{}, exp int) (bool, error) {
+	newVal, err := c.writeVal(newVal)
+	if err != nil {
+		return false, err
+	}
+
+	expected, ok, err := c.storedVal(ctx, key, oldVal)
+	if err != nil || !ok {
+		return false, err
+	}
+
+	args := []interface{}{"1", key, expected, newVal, strconv.Itoa(exp)}
+	resp, err := c.do(ctx, cmdEvalSha, casScript.Hash(), args...)
+	if err != nil && strings.HasPrefix(err.Error(), "NOSCRIPT") {
+		resp, err = c.do(ctx, cmdEval, luascript.CompareAndSwap, args...)
+	}
+	if err != nil || resp.Integer != 1 {
+		return false, err
+	}
+	// the key is not the first argument of the EVAL
+	c.memDel(key)
+
+	return true, nil
 }
 
-// Append value to the end of the key
-func (c *Cache) Append(ctx context.Context, key, val string) error {
-	return c.write(ctx, cmdAppend, key, val)
+// storedVal returns the value as it is stored in the server.
+//
+// The values are compressed or encrypted before being stored, so the stored value
+// is read from the server and compared with the given value.
+// It returns false if the stored value is not equal to the given value
+func (c *Cache) storedVal(ctx context.Context, key string, val interface{}) (interface{}, bool, error) {
+	if c.plainVal() {
+		return redisarg.Format(val), true, nil
+	}
+
+	resp, err := c.do(ctx, cmdGet, key)
+	if err != nil || c.isNullString(resp) {
+		return nil, false, err
+	}
+	str, err := c.readStr(resp.Str)
+	if err != nil {
+		return nil, false, err
+	}
+	return resp.Str, str == string(redisarg.Format(val)), nil
 }
 
 // MSet set multiple key values at once.
@@ -130,7 +712,20 @@ func (c *Cache) MSet(ctx context.Context, values ...interface{}) error {
 		return ErrInvalidArgs
 	}
 
-	_, err := c._do(ctx, cmdMSet, values...)
+	args := make([]interface{}, lenVal)
+	for i, val := range values {
+		if i%2 == 0 {
+			args[i] = val
+			continue
+		}
+		encoded, err := c.writeVal(val)
+		if err != nil {
+			return err
+		}
+		args[i] = encoded
+	}
+
+	_, err := c._do(ctx, cmdMSet, args...)
 	if err != nil {
 		return err
 	}
@@ -172,38 +767,68 @@ func (c *Cache) MGet(ctx context.Context, exp int, keys ...string) ([]StringValu
 		getIndexes = append(getIndexes, i)
 	}
 
+	for _, key := range getKeys {
+		c.pending.Start(key.(string))
+	}
+	vals := make([]*cacheVal, len(getKeys))
+	defer func() {
+		for i, key := range getKeys {
+			key := key.(string)
+			c.pending.Finish(key, func() {
+				if vals[i] != nil {
+					c.memSet(key, *vals[i], tsExp)
+				}
+			})
+		}
+	}()
+
 	resp, err := c._do(ctx, cmdMGet, getKeys...)
 	if err != nil {
 		return nil, err
 	}
 
 	for i, elem := range resp.Elems {
+		str, err := c.readStr(elem.Str)
+		if err != nil {
+			return nil, err
+		}
 		strVal := StringValue{
 			Nil: c.isNullString(elem),
-			Val: elem.Str,
+			Val: str,
 		}
 		results[getIndexes[i]] = strVal
 		if !strVal.Nil {
-			c.memSet(fmt.Sprintf("%s", (getKeys[i])), strVal.Val, tsExp)
+			vals[i] = &cacheVal{typ: cacheTypString, val: strVal.Val}
 		}
 	}
 	return results, nil
 }
 
 func (c *Cache) write(ctx context.Context, cmd, key string, args ...interface{}) error {
-	_, err := c.do(ctx, cmd, key, args...)
+	_, err := c.writeResp(ctx, cmd, key, args...)
+	return err
+}
+
+// writeResp executes the write command and returns the server response.
+//
+// It deletes the key from the in memory cache on success
+func (c *Cache) writeResp(ctx context.Context, cmd, key string, args ...interface{}) (*resp3.Value, error) {
+	resp, err := c.do(ctx, cmd, key, args...)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
 	// delete from in mem cache
 	c.memDel(key)
-	return nil
+	return resp, nil
 }
 
 // Close the strings cache and release it's all resources
 func (c *Cache) Close() error {
-	c.pool.Close()
+	c.closeOnce.Do(func() {
+		close(c.closeCh)
+		c.pool.Close()
+	})
 	return nil
 }
 
@@ -220,9 +845,7 @@ func (c *Cache) _do(ctx context.Context, cmd interface{}, args ...interface{}) (
 	}
 	defer conn.Close()
 
-	resp, err := conn.Do(ctx, cmd, args...)
-
-	return resp, err
+	return doConn(ctx, conn, cmd, args...)
 }
 
 func (c *Cache) get(ctx context.Context, cmd, key interface{}, args ...interface{}) (*resp3.Value, error) {
@@ -247,49 +870,126 @@ func (c *Cache) isNullString(resp *resp3.Value) bool {
 // it also add the key to the slots map
 func (c *Cache) memSet(key string, val interface{}, exp time.Duration) {
 	// add in cache
-	c.memcache.Set(key, val, exp)
+	c.part(key).memcache.Set(key, val, exp)
 }
 
+// memSetWithDeps sets the value which depends on the given keys,
+// it is deleted when one of the keys is deleted
+func (c *Cache) memSetWithDeps(key string, val interface{}, exp time.Duration, deps []string) {
+	for _, dep := range deps {
+		c.deps.add(dep, key)
+	}
+	c.memSet(key, val, exp)
+}
+
+// memDel deletes the key and it's dependents
 func (c *Cache) memDel(key string) {
-	c.memcache.Delete(key)
+	c.part(key).memcache.Delete(key)
+	for _, dep := range c.deps.take(key) {
+		c.part(dep).memcache.Delete(dep)
+	}
 }
 
 func (c *Cache) memGet2(key string) (cacheVal, bool) {
-	item := c.memcache.Get(key)
+	p := c.part(key)
+	item := p.memcache.Get(key)
 	if item == nil {
+		atomic.AddUint64(&p.misses, 1)
 		return cacheVal{}, false
 	}
 	if item.Expired() {
-		c.memcache.Delete(key)
+		atomic.AddUint64(&p.misses, 1)
+		c.memDel(key)
+		if !strings.HasPrefix(key, internalKeyPrefix) {
+			c.listeners.Notify(key, result.InvalidationExpiry)
+		}
 		return cacheVal{}, false
 	}
 
+	atomic.AddUint64(&p.hits, 1)
 	return item.Value().(cacheVal), true
 }
 
 func (c *Cache) memGet(key string) (string, bool) {
-	item := c.memcache.Get(key)
-	if item == nil {
-		return "", false
-	}
-	if item.Expired() {
-		c.memcache.Delete(key)
+	val, ok := c.memGet2(key)
+	if !ok || val.val == nil {
 		return "", false
 	}
-
-	return item.Value().(string), true
+	str, ok := val.val.(string)
+	return str, ok
 }
 
-// invalidate the given slot
+// invalidate the given key, it is called when the server sends the invalidation message
 func (c *Cache) invalidate(key string) {
-	c.memDel(key)
+	c.pending.Invalidate(key, func() {
+		c.memDel(key)
+	})
+	c.listeners.Notify(key, result.InvalidationServer)
+	c.notifyWatched(key)
+}
+
+// clear all of the in memory cache.
+//
+// it is called when we can't make sure that the cached values are still valid
+func (c *Cache) clear() {
+	c.pending.InvalidateAll(c.clearMem)
+	c.listeners.Notify("", result.InvalidationReconnect)
+	c.notifyAllWatched()
+}
+
+// flush clears all of the in memory cache, it is called when the server flushed the database
+func (c *Cache) flush() {
+	c.pending.InvalidateAll(c.clearMem)
+	c.listeners.Notify("", result.InvalidationFlush)
+	c.notifyAllWatched()
+}
+
+// OnInvalidate registers the func to be called when the key is removed from the in memory cache.
+//
+// The func is called synchronously, it must not block or call the Cache
+func (c *Cache) OnInvalidate(fn result.InvalidateFunc) {
+	c.listeners.Add(fn)
+}
+
+func (c *Cache) clearMem() {
+	for _, p := range c.parts {
+		p.memcache.Clear()
+	}
+	c.deps.clear()
 }
 
 const (
-	cmdSet    = "SET"
-	cmdGet    = "GET"
-	cmdDel    = "DEL"
-	cmdAppend = "APPEND"
-	cmdMSet   = "MSET"
-	cmdMGet   = "MGET"
+	cmdSet      = "SET"
+	cmdGet      = "GET"
+	cmdDel      = "DEL"
+	cmdWatch    = "WATCH"
+	cmdUnwatch  = "UNWATCH"
+	cmdMulti    = "MULTI"
+	cmdExec     = "EXEC"
+	cmdSAdd     = "SADD"
+	cmdSRem     = "SREM"
+	cmdSScan    = "SSCAN"
+	cmdUnlink   = "UNLINK"
+	cmdExists   = "EXISTS"
+	cmdAppend   = "APPEND"
+	cmdSetRange = "SETRANGE"
+	cmdGetRange = "GETRANGE"
+	cmdStrLen   = "STRLEN"
+	cmdMSet     = "MSET"
+	cmdMGet     = "MGET"
+
+	cmdIncr        = "INCR"
+	cmdIncrBy      = "INCRBY"
+	cmdDecr        = "DECR"
+	cmdDecrBy      = "DECRBY"
+	cmdIncrByFloat = "INCRBYFLOAT"
+	cmdExpire      = "EXPIRE"
+	cmdPExpire     = "PEXPIRE"
+	cmdPersist     = "PERSIST"
+	cmdTTL         = "TTL"
+	cmdGetEx       = "GETEX"
+	cmdEval        = "EVAL"
+	cmdEvalSha     = "EVALSHA"
 )
+
+var casScript = redis.NewScript(1, luascript.CompareAndSwap)
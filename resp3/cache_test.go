@@ -2,6 +2,7 @@ package resp3
 
 import (
 	"context"
+	"github.com/iwanbk/rimcu/internal/redigo/redis"
 	"github.com/iwanbk/rimcu/result"
 	"log"
 	"os"
@@ -356,6 +357,552 @@ func TestMGet_Basic(t *testing.T) {
 	}
 }
 
+// Test that Incr invalidates memcache in other nodes
+func TestStringsCache_Incr_Invalidate(t *testing.T) {
+	ctx := context.Background()
+
+	scs, cleanup := createStringsCacheTestClient(t, 2)
+	defer cleanup()
+
+	var (
+		sc1, sc2 = scs[0], scs[1]
+		key1     = generateRandomKey()
+	)
+
+	err := sc1.Setex(ctx, key1, 10, testExp)
+	require.NoError(t, err)
+
+	// init memcache of the second node
+	res, err := sc2.Get(ctx, key1, testExp)
+	require.NoError(t, err)
+	val, err := res.Int64()
+	require.NoError(t, err)
+	require.Equal(t, int64(10), val)
+
+	newVal, err := sc1.IncrBy(ctx, key1, 5)
+	require.NoError(t, err)
+	require.Equal(t, int64(15), newVal)
+
+	time.Sleep(syncTimeWait)
+
+	_, ok := sc2.memGet2(key1)
+	require.False(t, ok)
+
+	newVal, err = sc2.Decr(ctx, key1)
+	require.NoError(t, err)
+	require.Equal(t, int64(14), newVal)
+}
+
+// CompareAndSwap must only swap the value if the current value equals to the old value
+func TestStringsCache_CompareAndSwap(t *testing.T) {
+	ctx := context.Background()
+
+	scs, cleanup := createStringsCacheTestClient(t, 1)
+	defer cleanup()
+
+	var (
+		sc1  = scs[0]
+		key1 = generateRandomKey()
+	)
+
+	ok, err := sc1.SetNX(ctx, key1, "val_1", testExp)
+	require.NoError(t, err)
+	require.True(t, ok)
+
+	ok, err = sc1.SetNX(ctx, key1, "val_2", testExp)
+	require.NoError(t, err)
+	require.False(t, ok)
+
+	// init memcache
+	_, err = sc1.Get(ctx, key1, testExp)
+	require.NoError(t, err)
+
+	ok, err = sc1.CompareAndSwap(ctx, key1, "val_2", "val_3", testExp)
+	require.NoError(t, err)
+	require.False(t, ok)
+
+	ok, err = sc1.SetNX(ctx, key1, "val_2", testExp)
+	require.NoError(t, err)
+	require.False(t, ok)
+
+	// the rejected writes don't invalidate the memcache
+	_, ok = sc1.memGet2(key1)
+	require.True(t, ok)
+
+	ok, err = sc1.CompareAndSwap(ctx, key1, "val_1", "val_3", testExp)
+	require.NoError(t, err)
+	require.True(t, ok)
+
+	_, ok = sc1.memGet2(key1)
+	require.False(t, ok)
+
+	old, err := sc1.GetSet(ctx, key1, "val_4", testExp)
+	require.NoError(t, err)
+	checkStringEqual(t, "val_3", old)
+}
+
+// Expire must update the server TTL and invalidate the memcache
+func TestStringsCache_Expire(t *testing.T) {
+	ctx := context.Background()
+
+	scs, cleanup := createStringsCacheTestClient(t, 1)
+	defer cleanup()
+
+	var (
+		sc1  = scs[0]
+		key1 = generateRandomKey()
+	)
+
+	err := sc1.Setex(ctx, key1, "val_1", testExp)
+	require.NoError(t, err)
+
+	// init memcache
+	_, err = sc1.Get(ctx, key1, testExp)
+	require.NoError(t, err)
+
+	ok, err := sc1.Expire(ctx, key1, 10)
+	require.NoError(t, err)
+	require.True(t, ok)
+
+	_, ok = sc1.memGet2(key1)
+	require.False(t, ok)
+
+	ttl, err := sc1.TTL(ctx, key1)
+	require.NoError(t, err)
+	require.True(t, ttl > 0 && ttl <= 10)
+
+	res, err := sc1.GetEx(ctx, key1, 100)
+	require.NoError(t, err)
+	checkStringEqual(t, "val_1", res)
+
+	ok, err = sc1.Persist(ctx, key1)
+	require.NoError(t, err)
+	require.True(t, ok)
+
+	ttl, err = sc1.TTL(ctx, key1)
+	require.NoError(t, err)
+	require.Equal(t, int64(-1), ttl)
+}
+
+// GetRange & StrLen must be served from the memcache when the key is cached
+func TestStringsCache_Append_GetRange(t *testing.T) {
+	ctx := context.Background()
+
+	scs, cleanup := createStringsCacheTestClient(t, 1)
+	defer cleanup()
+
+	var (
+		sc1  = scs[0]
+		key1 = generateRandomKey()
+	)
+
+	n, err := sc1.Append(ctx, key1, "Hello")
+	require.NoError(t, err)
+	require.Equal(t, int64(5), n)
+
+	n, err = sc1.Append(ctx, key1, " World")
+	require.NoError(t, err)
+	require.Equal(t, int64(11), n)
+
+	// from server
+	str, err := sc1.GetRange(ctx, key1, -5, -1)
+	require.NoError(t, err)
+	require.Equal(t, "World", str)
+
+	// init memcache
+	_, err = sc1.Get(ctx, key1, testExp)
+	require.NoError(t, err)
+
+	// from memcache
+	str, err = sc1.GetRange(ctx, key1, 0, 4)
+	require.NoError(t, err)
+	require.Equal(t, "Hello", str)
+
+	n, err = sc1.StrLen(ctx, key1)
+	require.NoError(t, err)
+	require.Equal(t, int64(11), n)
+
+	n, err = sc1.SetRange(ctx, key1, 6, "Redis")
+	require.NoError(t, err)
+	require.Equal(t, int64(11), n)
+
+	_, ok := sc1.memGet2(key1)
+	require.False(t, ok)
+
+	str, err = sc1.GetRange(ctx, key1, 6, -1)
+	require.NoError(t, err)
+	require.Equal(t, "Redis", str)
+}
+
+// Test that the cached nonexistent key is answered with ErrNotFound from the negative entry
+func TestStringsCache_Get_CacheNegative(t *testing.T) {
+	ctx := context.Background()
+
+	scs, cleanup := createStringsCacheTestClient(t, 1)
+	defer cleanup()
+
+	var (
+		sc1  = scs[0]
+		key1 = generateRandomKey()
+	)
+	sc1.cacheNegative = true
+
+	// from the server
+	_, err := sc1.Get(ctx, key1, testExp)
+	require.Equal(t, ErrNotFound, err)
+
+	val, ok := sc1.memGet2(key1)
+	require.True(t, ok)
+	require.Nil(t, val.val)
+
+	// from the negative entry
+	_, err = sc1.Get(ctx, key1, testExp)
+	require.Equal(t, ErrNotFound, err)
+}
+
+// Del with multiple keys must delete all of them in both server and memcache
+func TestStringsCache_Del_MultiKeys_Exists(t *testing.T) {
+	ctx := context.Background()
+
+	scs, cleanup := createStringsCacheTestClient(t, 1)
+	defer cleanup()
+
+	var (
+		sc1  = scs[0]
+		keys = []string{generateRandomKey(), generateRandomKey(), generateRandomKey()}
+	)
+
+	for _, key := range keys {
+		err := sc1.Setex(ctx, key, "val", testExp)
+		require.NoError(t, err)
+
+		// init memcache
+		_, err = sc1.Get(ctx, key, testExp)
+		require.NoError(t, err)
+	}
+
+	n, err := sc1.Exists(ctx, keys...)
+	require.NoError(t, err)
+	require.Equal(t, int64(3), n)
+
+	err = sc1.Del(ctx, keys[0], keys[1])
+	require.NoError(t, err)
+
+	for _, key := range keys[:2] {
+		_, ok := sc1.memGet2(key)
+		require.False(t, ok)
+	}
+
+	n, err = sc1.Exists(ctx, keys...)
+	require.NoError(t, err)
+	require.Equal(t, int64(1), n)
+}
+
+func TestStringsCache_ExecBatch(t *testing.T) {
+	ctx := context.Background()
+
+	scs, cleanup := createStringsCacheTestClient(t, 1)
+	defer cleanup()
+
+	var (
+		sc1      = scs[0]
+		cached   = generateRandomKey()
+		written  = generateRandomKey()
+		notFound = generateRandomKey()
+	)
+
+	err := sc1.Setex(ctx, cached, "cached", testExp)
+	require.NoError(t, err)
+
+	// init memcache
+	_, err = sc1.Get(ctx, cached, testExp)
+	require.NoError(t, err)
+
+	ops := []*result.BatchOp{
+		{Type: result.BatchGet, Keys: []string{cached}, Exp: testExp},
+		{Type: result.BatchSetex, Keys: []string{written}, Val: "written", Exp: testExp},
+		{Type: result.BatchGet, Keys: []string{written}, Exp: testExp},
+		{Type: result.BatchGet, Keys: []string{notFound}, Exp: testExp},
+		{Type: result.BatchDel, Keys: []string{cached}},
+	}
+	err = sc1.ExecBatch(ctx, ops)
+	require.NoError(t, err)
+
+	// answered from the memory cache
+	require.NoError(t, ops[0].Err)
+	require.True(t, ops[0].Res.FromLocalCache())
+	checkStringEqual(t, "cached", ops[0].Res)
+
+	// written in the same batch
+	require.NoError(t, ops[1].Err)
+	require.NoError(t, ops[2].Err)
+	require.False(t, ops[2].Res.FromLocalCache())
+	checkStringEqual(t, "written", ops[2].Res)
+
+	require.Equal(t, ErrNotFound, ops[3].Err)
+
+	require.NoError(t, ops[4].Err)
+	_, ok := sc1.memGet2(cached)
+	require.False(t, ok)
+
+	// answered from the negative entry
+	sc1.cacheNegative = true
+	_, err = sc1.Get(ctx, notFound, testExp)
+	require.Equal(t, ErrNotFound, err)
+
+	ops = []*result.BatchOp{
+		{Type: result.BatchGet, Keys: []string{notFound}, Exp: testExp},
+	}
+	err = sc1.ExecBatch(ctx, ops)
+	require.NoError(t, err)
+	require.Equal(t, ErrNotFound, ops[0].Err)
+	require.Nil(t, ops[0].Res)
+}
+
+func TestStringsCache_Tx(t *testing.T) {
+	ctx := context.Background()
+
+	scs, cleanup := createStringsCacheTestClient(t, 2)
+	defer cleanup()
+
+	var (
+		sc1 = scs[0]
+		sc2 = scs[1]
+		key = generateRandomKey()
+	)
+
+	err := sc1.Setex(ctx, key, "1", testExp)
+	require.NoError(t, err)
+
+	// init memcache
+	_, err = sc1.Get(ctx, key, testExp)
+	require.NoError(t, err)
+
+	// the watched key is modified by other client
+	err = sc1.Tx(ctx, []string{key}, func(tx result.Tx) error {
+		err := sc2.Setex(ctx, key, "2", testExp)
+		require.NoError(t, err)
+
+		tx.Setex(key, "3", testExp)
+		return nil
+	})
+	require.Equal(t, result.ErrTxConflict, err)
+
+	err = sc1.Tx(ctx, []string{key}, func(tx result.Tx) error {
+		res, err := tx.Get(ctx, key)
+		require.NoError(t, err)
+		checkStringEqual(t, "2", res)
+
+		tx.Setex(key, "3", testExp)
+		return nil
+	})
+	require.NoError(t, err)
+
+	// the memcache was invalidated
+	_, ok := sc1.memGet2(key)
+	require.False(t, ok)
+
+	res, err := sc1.Get(ctx, key, testExp)
+	require.NoError(t, err)
+	checkStringEqual(t, "3", res)
+}
+
+// Test that the script result is invalidated when the read key is modified by other node
+func TestStringsCache_EvalRO_Invalidate(t *testing.T) {
+	ctx := context.Background()
+
+	scs, cleanup := createStringsCacheTestClient(t, 2)
+	defer cleanup()
+
+	var (
+		sc1, sc2 = scs[0], scs[1]
+		key1     = generateRandomKey()
+		script   = redis.NewScript(-1, "return redis.call('GET', KEYS[1])")
+	)
+
+	err := sc1.Setex(ctx, key1, "val_1", testExp)
+	require.NoError(t, err)
+
+	res, err := sc1.EvalRO(ctx, script, []string{key1}, testExp)
+	require.NoError(t, err)
+	require.False(t, res.FromLocalCache())
+	checkStringEqual(t, "val_1", res)
+
+	// cached
+	res, err = sc1.EvalRO(ctx, script, []string{key1}, testExp)
+	require.NoError(t, err)
+	require.True(t, res.FromLocalCache())
+
+	err = sc2.Setex(ctx, key1, "val_2", testExp)
+	require.NoError(t, err)
+
+	time.Sleep(syncTimeWait)
+
+	res, err = sc1.EvalRO(ctx, script, []string{key1}, testExp)
+	require.NoError(t, err)
+	require.False(t, res.FromLocalCache())
+	checkStringEqual(t, "val_2", res)
+}
+
+// Test that the computed value is invalidated when the dep key is modified by other node
+func TestStringsCache_Compute_Invalidate(t *testing.T) {
+	ctx := context.Background()
+
+	scs, cleanup := createStringsCacheTestClient(t, 2)
+	defer cleanup()
+
+	var (
+		sc1, sc2 = scs[0], scs[1]
+		deps     = []string{generateRandomKey(), generateRandomKey()}
+		name     = generateRandomKey()
+		numCalls int
+	)
+
+	compute := func(ctx context.Context) (interface{}, error) {
+		numCalls++
+		return numCalls, nil
+	}
+
+	val, err := sc1.Compute(ctx, name, deps, testExp, compute)
+	require.NoError(t, err)
+	require.Equal(t, 1, val)
+
+	// cached
+	val, err = sc1.Compute(ctx, name, deps, testExp, compute)
+	require.NoError(t, err)
+	require.Equal(t, 1, val)
+
+	// modify the dep key
+	err = sc2.Setex(ctx, deps[1], "val", testExp)
+	require.NoError(t, err)
+
+	time.Sleep(syncTimeWait)
+
+	val, err = sc1.Compute(ctx, name, deps, testExp, compute)
+	require.NoError(t, err)
+	require.Equal(t, 2, val)
+}
+
+// Test that InvalidateTag deletes the tagged keys and invalidates memcache in other nodes
+func TestStringsCache_InvalidateTag(t *testing.T) {
+	ctx := context.Background()
+
+	scs, cleanup := createStringsCacheTestClient(t, 2)
+	defer cleanup()
+
+	var (
+		sc1, sc2 = scs[0], scs[1]
+		tag      = generateRandomKey()
+		tagged   = []string{generateRandomKey(), generateRandomKey()}
+		untagged = generateRandomKey()
+	)
+
+	for _, key := range tagged {
+		err := sc1.SetexTags(ctx, key, "val", testExp, tag)
+		require.NoError(t, err)
+	}
+	err := sc1.Setex(ctx, untagged, "val", testExp)
+	require.NoError(t, err)
+
+	// init memcache
+	for _, key := range append(tagged, untagged) {
+		_, err = sc2.Get(ctx, key, testExp)
+		require.NoError(t, err)
+	}
+
+	err = sc1.InvalidateTag(ctx, tag)
+	require.NoError(t, err)
+
+	time.Sleep(syncTimeWait)
+
+	for _, key := range tagged {
+		_, ok := sc2.memGet2(key)
+		require.False(t, ok)
+
+		_, err = sc2.Get(ctx, key, testExp)
+		require.Equal(t, ErrNotFound, err)
+	}
+	_, ok := sc2.memGet2(untagged)
+	require.True(t, ok)
+}
+
+// Test that the invalidation callback is called when the key is modified by other node
+func TestStringsCache_OnInvalidate(t *testing.T) {
+	ctx := context.Background()
+
+	scs, cleanup := createStringsCacheTestClient(t, 2)
+	defer cleanup()
+
+	var (
+		sc1, sc2 = scs[0], scs[1]
+		key1     = generateRandomKey()
+		keyCh    = make(chan string, 1)
+	)
+
+	sc1.OnInvalidate(func(key string, reason result.InvalidationReason) {
+		if key != key1 || reason != result.InvalidationServer {
+			return
+		}
+		select {
+		case keyCh <- key:
+		default:
+		}
+	})
+
+	err := sc1.Setex(ctx, key1, "val_1", testExp)
+	require.NoError(t, err)
+
+	// init memcache
+	_, err = sc1.Get(ctx, key1, testExp)
+	require.NoError(t, err)
+
+	err = sc2.Setex(ctx, key1, "val_2", testExp)
+	require.NoError(t, err)
+
+	select {
+	case key := <-keyCh:
+		require.Equal(t, key1, key)
+	case <-time.After(syncTimeWait):
+		t.Fatal("timeout waiting for the invalidation callback")
+	}
+}
+
+// Test that the watcher receives the changes of the watched key
+func TestStringsCache_Watch(t *testing.T) {
+	scs, cleanup := createStringsCacheTestClient(t, 2)
+	defer cleanup()
+
+	var (
+		sc1, sc2 = scs[0], scs[1]
+		key      = generateRandomKey()
+	)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	ch, err := sc1.Watch(ctx, key)
+	require.NoError(t, err)
+
+	// the key is watched again after the first change
+	for _, val := range []string{"val_1", "val_2"} {
+		err = sc2.Setex(ctx, key, val, testExp)
+		require.NoError(t, err)
+
+		select {
+		case changed := <-ch:
+			require.Equal(t, key, changed)
+		case <-time.After(syncTimeWait):
+			t.Fatal("timeout waiting for the change notification")
+		}
+
+		// the value is not cached
+		_, ok := sc1.memGet2(key)
+		require.False(t, ok)
+
+		time.Sleep(syncTimeWait)
+	}
+}
+
 func generateRandomKey() string {
 	return xid.New().String()
 }
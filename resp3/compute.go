@@ -0,0 +1,50 @@
+package resp3
+
+import (
+	"context"
+	"time"
+)
+
+// computeKeyPrefix is the prefix of the in memory cache key of the computed values
+const computeKeyPrefix = internalKeyPrefix + "compute:"
+
+// Compute returns the value computed by the fn, the value is cached in the memory cache
+// under the given name and it is invalidated when one of the deps keys is invalidated.
+//
+// The deps keys are tracked before calling the fn, and the value is not cached
+// if one of them is invalidated during the computation.
+func (c *Cache) Compute(ctx context.Context, name string, deps []string, exp int,
+	fn func(ctx context.Context) (interface{}, error)) (interface{}, error) {
+	if len(deps) == 0 {
+		// nothing to invalidate the computed value
+		return nil, ErrInvalidArgs
+	}
+
+	cacheKey := computeKeyPrefix + name
+	if val, ok := c.memGet2(cacheKey); ok {
+		return val.val, nil
+	}
+
+	for _, dep := range deps {
+		c.pending.Start(dep)
+	}
+	val, err := c.compute(ctx, deps, fn)
+	c.pending.FinishAll(deps, func() {
+		if err == nil {
+			c.memSetWithDeps(cacheKey, cacheVal{val: val}, time.Duration(exp)*time.Second, deps)
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+	return val, nil
+}
+
+// compute tracks the deps keys and then calls the fn
+func (c *Cache) compute(ctx context.Context, deps []string,
+	fn func(ctx context.Context) (interface{}, error)) (interface{}, error) {
+	if err := c.trackKeys(ctx, deps); err != nil {
+		return nil, err
+	}
+	return fn(ctx)
+}
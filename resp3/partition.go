@@ -0,0 +1,64 @@
+package resp3
+
+import (
+	"sort"
+	"strings"
+	"sync/atomic"
+
+	"github.com/iwanbk/rimcu/result"
+	"github.com/karlseguin/ccache"
+)
+
+// memPartition is a part of the in memory cache which has it's own size quota,
+// so the keys of a partition never evict the keys of the other partitions.
+type memPartition struct {
+	prefix   string
+	size     int
+	memcache *ccache.Cache
+
+	hits   uint64
+	misses uint64
+}
+
+func newMemPartition(prefix string, size int) *memPartition {
+	return &memPartition{
+		prefix:   prefix,
+		size:     size,
+		memcache: ccache.New(ccache.Configure().MaxSize(int64(size))),
+	}
+}
+
+// addPartition adds partition of the keys with the given prefix,
+// it must be called before the cache is used.
+func (c *Cache) addPartition(prefix string, size int) {
+	c.parts = append(c.parts, newMemPartition(prefix, size))
+
+	// the longest prefix is matched first, the default partition is the last
+	sort.SliceStable(c.parts, func(i, j int) bool {
+		return len(c.parts[i].prefix) > len(c.parts[j].prefix)
+	})
+}
+
+// part returns the partition of the key
+func (c *Cache) part(key string) *memPartition {
+	for _, p := range c.parts {
+		if strings.HasPrefix(key, p.prefix) {
+			return p
+		}
+	}
+	// unreachable, the default partition matches all keys
+	return c.parts[len(c.parts)-1]
+}
+
+// Stats returns the stats of the in memory cache partition which hosts the keys with the given prefix.
+//
+// The evictions are not counted, the eviction is not observable in the underlying cache.
+func (c *Cache) Stats(prefix string) result.CacheStats {
+	p := c.part(prefix)
+	return result.CacheStats{
+		Size:     p.memcache.ItemCount(),
+		Capacity: p.size,
+		Hits:     atomic.LoadUint64(&p.hits),
+		Misses:   atomic.LoadUint64(&p.misses),
+	}
+}
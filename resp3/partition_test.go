@@ -0,0 +1,34 @@
+package resp3
+
+import (
+	"testing"
+	"time"
+
+	"github.com/iwanbk/rimcu/result"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCache_PartitionCapacity(t *testing.T) {
+	c := New(Config{
+		ServerAddr: "127.0.0.1:0", // never connected
+		CacheSize:  100,
+		Partitions: []result.PartitionConfig{
+			{Prefix: "tenant1:", CacheSize: 10},
+			{Prefix: "tenant2:"},
+		},
+	})
+	defer c.Close()
+
+	require.Equal(t, 100, c.Stats("").Capacity)
+	require.Equal(t, 10, c.Stats("tenant1:").Capacity)
+	require.Equal(t, 100, c.Stats("tenant2:").Capacity)
+
+	c.memSet("tenant1:key", cacheVal{val: "val"}, time.Minute)
+	_, ok := c.memGet2("tenant1:key")
+	require.True(t, ok)
+	_, ok = c.memGet2("key")
+	require.False(t, ok)
+
+	require.Equal(t, result.CacheStats{Size: 1, Capacity: 10, Hits: 1}, c.Stats("tenant1:"))
+	require.Equal(t, result.CacheStats{Capacity: 100, Misses: 1}, c.Stats(""))
+}
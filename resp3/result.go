@@ -1,10 +1,15 @@
 package resp3
 
-import "fmt"
+import (
+	"fmt"
+
+	"github.com/iwanbk/rimcu/internal/redigo/redis"
+)
 
 type StringsResult struct {
 	typ            cacheTyp
 	val            interface{}
+	decoded        interface{}
 	fromLocalCache bool
 }
 
@@ -12,6 +17,7 @@ func newStringsResult(cv cacheVal, fromLocalCache bool) *StringsResult {
 	return &StringsResult{
 		typ:            cv.typ,
 		val:            cv.val,
+		decoded:        cv.decoded,
 		fromLocalCache: fromLocalCache,
 	}
 }
@@ -34,9 +40,59 @@ func (sr *StringsResult) String() (string, error) {
 	return sr.val.(string), nil
 }
 
+// Decoded returns the decoded value of the result,
+// it is only available on the result of the GetDecoded
+func (sr *StringsResult) Decoded() interface{} {
+	return sr.decoded
+}
+
+// Int64 returns int64 representation of the result
+func (sr *StringsResult) Int64() (int64, error) {
+	return redis.Int64(sr.raw())
+}
+
+// Float64 returns float64 representation of the result
+func (sr *StringsResult) Float64() (float64, error) {
+	return redis.Float64(sr.raw())
+}
+
+// Bytes returns []byte representation of the result
+func (sr *StringsResult) Bytes() ([]byte, error) {
+	return redis.Bytes(sr.raw())
+}
+
+// IsNil returns true if the result has no value
+func (sr *StringsResult) IsNil() bool {
+	return sr.val == nil
+}
+
+// Scan copies the result to the value pointed by dest
+func (sr *StringsResult) Scan(dest interface{}) error {
+	raw, err := sr.raw()
+	if err != nil {
+		return err
+	}
+	_, err = redis.Scan([]interface{}{raw}, dest)
+	return err
+}
+
+// raw returns the result as the RESP2 reply, so it could be
+// converted using the redigo helpers
+func (sr *StringsResult) raw() (interface{}, error) {
+	switch val := sr.val.(type) {
+	case nil:
+		return nil, nil
+	case string:
+		return []byte(val), nil
+	default:
+		return nil, fmt.Errorf("not a string")
+	}
+}
+
 type cacheVal struct {
-	typ cacheTyp
-	val interface{}
+	typ     cacheTyp
+	val     interface{}
+	decoded interface{}
 }
 
 type cacheTyp int8
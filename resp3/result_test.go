@@ -0,0 +1,28 @@
+package resp3
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestStringsResult_Accessors(t *testing.T) {
+	sr := newStringsResult(cacheVal{typ: cacheTypString, val: "1.5"}, false)
+
+	f, err := sr.Float64()
+	require.NoError(t, err)
+	require.Equal(t, 1.5, f)
+
+	_, err = sr.Int64()
+	require.Error(t, err)
+
+	b, err := sr.Bytes()
+	require.NoError(t, err)
+	require.Equal(t, []byte("1.5"), b)
+
+	var dest string
+	require.NoError(t, sr.Scan(&dest))
+	require.Equal(t, "1.5", dest)
+
+	require.False(t, sr.IsNil())
+}
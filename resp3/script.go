@@ -0,0 +1,193 @@
+package resp3
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/iwanbk/resp3"
+	"github.com/iwanbk/rimcu/internal/redigo/redis"
+	"github.com/iwanbk/rimcu/internal/redisarg"
+	"github.com/iwanbk/rimcu/result"
+)
+
+// scriptKeyPrefix is the prefix of the in memory cache key of the script results
+const scriptKeyPrefix = internalKeyPrefix + "script:"
+
+// EvalRO evaluates the read only script with the given keys and args.
+//
+// The keys are the keys read by the script, the result is cached in the memory cache
+// keyed by the script hash & the arguments, and it is invalidated when
+// one of the keys is invalidated.
+func (c *Cache) EvalRO(ctx context.Context, script *redis.Script, keys []string, exp int,
+	args ...interface{}) (result.StringsResult, error) {
+	if len(keys) == 0 {
+		// nothing to invalidate the cached result
+		return nil, ErrInvalidArgs
+	}
+
+	cacheKey := scriptCacheKey(script.Hash(), keys, args)
+	if val, ok := c.memGet2(cacheKey); ok {
+		return newStringsResult(val, true), nil
+	}
+
+	for _, key := range keys {
+		c.pending.Start(key)
+	}
+	val, err := c.evalVal(ctx, script, keys, args)
+	c.pending.FinishAll(keys, func() {
+		if err == nil {
+			c.memSetWithDeps(cacheKey, val, time.Duration(exp)*time.Second, keys)
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+	return newStringsResult(val, false), nil
+}
+
+// evalVal evaluates the script and converts the result to the cached value
+func (c *Cache) evalVal(ctx context.Context, script *redis.Script, keys []string, args []interface{}) (cacheVal, error) {
+	resp, err := c.evalRO(ctx, script, keys, args)
+	if err != nil {
+		return cacheVal{}, err
+	}
+	str, err := scriptResult(resp)
+	if err != nil {
+		return cacheVal{}, err
+	}
+	return cacheVal{
+		typ: cacheTypString,
+		val: str,
+	}, nil
+}
+
+func (c *Cache) evalRO(ctx context.Context, script *redis.Script, keys []string, args []interface{}) (*resp3.Value, error) {
+	conn, err := c.pool.Get(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	keysAndArgs := []interface{}{strconv.Itoa(len(keys))}
+	for _, key := range keys {
+		keysAndArgs = append(keysAndArgs, key)
+	}
+	for _, arg := range args {
+		keysAndArgs = append(keysAndArgs, string(redisarg.Format(arg)))
+	}
+
+	// the server might not track the keys read by the script,
+	// read them first using the same connection to make sure they are tracked
+	replies, err := conn.Pipeline(ctx, [][]interface{}{
+		append([]interface{}{cmdExists}, keysAndArgs[1:len(keys)+1]...),
+		append([]interface{}{cmdEvalSha, script.Hash()}, keysAndArgs...),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	resp := replies[1]
+	if isErrorResp(resp) && strings.HasPrefix(resp.Err, "NOSCRIPT") {
+		return doConn(ctx, conn, cmdEval, append([]interface{}{script.Src()}, keysAndArgs...)...)
+	}
+	if isErrorResp(resp) {
+		return nil, fmt.Errorf("%s", resp.Err)
+	}
+	return resp, nil
+}
+
+// scriptResult converts the script reply to the cached value,
+// only the scalar replies are supported
+func scriptResult(resp *resp3.Value) (interface{}, error) {
+	switch resp.Type {
+	case resp3.TypeNull:
+		return nil, nil
+	case resp3.TypeNumber:
+		return strconv.FormatInt(resp.Integer, 10), nil
+	case resp3.TypeDouble:
+		return strconv.FormatFloat(resp.Double, 'g', -1, 64), nil
+	case resp3.TypeBlobString, resp3.TypeSimpleString:
+		return resp.Str, nil
+	default:
+		return nil, fmt.Errorf("unsupported script result type: %c", resp.Type)
+	}
+}
+
+// scriptCacheKey returns the in memory cache key of the script result
+func scriptCacheKey(hash string, keys []string, args []interface{}) string {
+	var sb strings.Builder
+	sb.WriteString(scriptKeyPrefix)
+	sb.WriteString(hash)
+	sb.WriteString(":")
+	sb.WriteString(strconv.Itoa(len(keys)))
+	for _, key := range keys {
+		writeLenPrefixed(&sb, key)
+	}
+	for _, arg := range args {
+		writeLenPrefixed(&sb, string(redisarg.Format(arg)))
+	}
+	return sb.String()
+}
+
+func writeLenPrefixed(sb *strings.Builder, str string) {
+	sb.WriteString(":")
+	sb.WriteString(strconv.Itoa(len(str)))
+	sb.WriteString(":")
+	sb.WriteString(str)
+}
+
+// depKeys maps the keys to the cached keys which depend on them.
+//
+// The dependents are only removed when the key is deleted,
+// the expired dependents are removed lazily.
+type depKeys struct {
+	mtx sync.Mutex
+	m   map[string]map[string]struct{}
+}
+
+func newDepKeys() *depKeys {
+	return &depKeys{
+		m: make(map[string]map[string]struct{}),
+	}
+}
+
+func (dk *depKeys) add(dep, key string) {
+	dk.mtx.Lock()
+	defer dk.mtx.Unlock()
+
+	keys, ok := dk.m[dep]
+	if !ok {
+		keys = make(map[string]struct{})
+		dk.m[dep] = keys
+	}
+	keys[key] = struct{}{}
+}
+
+// take removes and returns the dependents of the dep key
+func (dk *depKeys) take(dep string) []string {
+	dk.mtx.Lock()
+	defer dk.mtx.Unlock()
+
+	keys, ok := dk.m[dep]
+	if !ok {
+		return nil
+	}
+	delete(dk.m, dep)
+
+	res := make([]string, 0, len(keys))
+	for key := range keys {
+		res = append(res, key)
+	}
+	return res
+}
+
+func (dk *depKeys) clear() {
+	dk.mtx.Lock()
+	defer dk.mtx.Unlock()
+
+	dk.m = make(map[string]map[string]struct{})
+}
@@ -0,0 +1,92 @@
+package resp3
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+)
+
+const (
+	// tagKeyPrefix is the prefix of the redis sets which index the keys of the tags
+	tagKeyPrefix = "rimcu:tag:"
+
+	// tagScanCount is the number of the tagged keys invalidated at once
+	tagScanCount = 1000
+)
+
+// SetexTags sets the key to hold the value with the given expiration second,
+// and attaches the tags to the key.
+//
+// The tag index is stored in the redis sets, so the tagged keys could be invalidated
+// by all nodes. The tag index is only cleaned by the InvalidateTag.
+func (c *Cache) SetexTags(ctx context.Context, key string, val interface{}, exp int, tags ...string) error {
+	val, err := c.writeVal(val)
+	if err != nil {
+		return err
+	}
+	return c.multi(ctx, func(tx *cacheTx) {
+		tx.queue([]string{key}, cmdSet, key, val, "EX", strconv.Itoa(exp))
+		for _, tag := range tags {
+			tx.queue(nil, cmdSAdd, c.tagKey(tag), key)
+		}
+	})
+}
+
+// InvalidateTag deletes all keys which have the given tag,
+// in both memory cache and redis server.
+//
+// The keys are deleted in batches, the keys tagged during
+// the invalidation might not be deleted
+func (c *Cache) InvalidateTag(ctx context.Context, tag string) error {
+	var (
+		tk     = c.tagKey(tag)
+		cursor = "0"
+	)
+	for {
+		next, keys, err := c.scanTag(ctx, tk, cursor)
+		if err != nil {
+			return err
+		}
+		if len(keys) > 0 {
+			err = c.multi(ctx, func(tx *cacheTx) {
+				del := []interface{}{cmdDel}
+				srem := []interface{}{cmdSRem, tk}
+				for _, key := range keys {
+					del = append(del, key)
+					srem = append(srem, key)
+				}
+				tx.queue(keys, del...)
+				tx.queue(nil, srem...)
+			})
+			if err != nil {
+				return err
+			}
+		}
+		if next == "0" {
+			return nil
+		}
+		cursor = next
+	}
+}
+
+// scanTag returns the next cursor and a batch of keys of the tag
+func (c *Cache) scanTag(ctx context.Context, tk, cursor string) (string, []string, error) {
+	resp, err := c._do(ctx, cmdSScan, tk, cursor, "COUNT", strconv.Itoa(tagScanCount))
+	if err != nil {
+		return "", nil, err
+	}
+	if len(resp.Elems) != 2 {
+		return "", nil, fmt.Errorf("unexpected SSCAN reply: %v", resp)
+	}
+
+	keys := make([]string, 0, len(resp.Elems[1].Elems))
+	for _, elem := range resp.Elems[1].Elems {
+		keys = append(keys, elem.Str)
+	}
+	return resp.Elems[0].Str, keys, nil
+}
+
+// tagKey returns the key of the tag index, it is put under the key prefix
+func (c *Cache) tagKey(tag string) string {
+	return c.keyPrefix + tagKeyPrefix + tag
+}
@@ -0,0 +1,170 @@
+package resp3
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+
+	"github.com/iwanbk/resp3"
+	"github.com/iwanbk/rimcu/internal/resp3pool"
+	"github.com/iwanbk/rimcu/result"
+)
+
+// cacheTx is the transaction of the Cache
+type cacheTx struct {
+	c    *Cache
+	conn *resp3pool.Conn
+
+	cmds [][]interface{}
+	keys []string // written keys
+	err  error    // error of the queued writes
+}
+
+// Tx executes the transaction func on a single connection which watches the given keys.
+//
+// The queued writes are executed using MULTI/EXEC, and the in memory cache
+// of the written keys are only invalidated after the EXEC succeed.
+// It returns result.ErrTxConflict if the watched keys were modified before the EXEC.
+func (c *Cache) Tx(ctx context.Context, watchKeys []string, fn func(tx result.Tx) error) error {
+	conn, err := c.pool.Get(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	if len(watchKeys) > 0 {
+		args := make([]interface{}, 0, len(watchKeys))
+		for _, key := range watchKeys {
+			args = append(args, key)
+		}
+		if _, err = doConn(ctx, conn, cmdWatch, args...); err != nil {
+			return err
+		}
+	}
+
+	tx := &cacheTx{
+		c:    c,
+		conn: conn,
+	}
+	if err = fn(tx); err == nil {
+		err = tx.err
+	}
+	if err != nil || len(tx.cmds) == 0 {
+		conn.Do(ctx, cmdUnwatch)
+		return err
+	}
+
+	return tx.exec(ctx)
+}
+
+// multi executes the writes queued by the fn atomically using MULTI/EXEC
+func (c *Cache) multi(ctx context.Context, fn func(tx *cacheTx)) error {
+	conn, err := c.pool.Get(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	tx := &cacheTx{
+		c:    c,
+		conn: conn,
+	}
+	fn(tx)
+	if tx.err != nil || len(tx.cmds) == 0 {
+		return tx.err
+	}
+	return tx.exec(ctx)
+}
+
+// exec executes the queued writes atomically
+func (tx *cacheTx) exec(ctx context.Context) error {
+	cmds := make([][]interface{}, 0, len(tx.cmds)+2)
+	cmds = append(cmds, []interface{}{cmdMulti})
+	cmds = append(cmds, tx.cmds...)
+	cmds = append(cmds, []interface{}{cmdExec})
+
+	replies, err := tx.conn.Pipeline(ctx, cmds)
+	if err != nil {
+		return err
+	}
+
+	resp := replies[len(replies)-1]
+	if tx.c.isNullString(resp) {
+		return result.ErrTxConflict
+	}
+	if isErrorResp(resp) {
+		// the transaction was discarded
+		return fmt.Errorf("%s", resp.Err)
+	}
+
+	// the transaction was executed, even if some of the commands failed
+	for _, key := range tx.keys {
+		tx.c.memDel(key)
+	}
+	for _, elem := range resp.Elems {
+		if isErrorResp(elem) {
+			return fmt.Errorf("%s", elem.Err)
+		}
+	}
+	return nil
+}
+
+// Get gets the value of the key from the server, bypassing the memory cache
+func (tx *cacheTx) Get(ctx context.Context, key string) (result.StringsResult, error) {
+	resp, err := doConn(ctx, tx.conn, cmdGet, key)
+	if err != nil {
+		return nil, err
+	}
+	if tx.c.isNullString(resp) {
+		return nil, ErrNotFound
+	}
+
+	str, err := tx.c.readStr(resp.Str)
+	if err != nil {
+		return nil, err
+	}
+	return newStringsResult(cacheVal{typ: cacheTypString, val: str}, false), nil
+}
+
+// Setex queues the set of the key with the given expiration second
+func (tx *cacheTx) Setex(key string, val interface{}, exp int) {
+	val, err := tx.c.writeVal(val)
+	if err != nil {
+		if tx.err == nil {
+			tx.err = err
+		}
+		return
+	}
+	tx.queue([]string{key}, cmdSet, key, val, "EX", strconv.Itoa(exp))
+}
+
+// Del queues the deletion of the keys
+func (tx *cacheTx) Del(keys ...string) {
+	cmd := []interface{}{cmdDel}
+	for _, key := range keys {
+		cmd = append(cmd, key)
+	}
+	tx.queue(keys, cmd...)
+}
+
+func (tx *cacheTx) queue(keys []string, cmd ...interface{}) {
+	tx.cmds = append(tx.cmds, cmd)
+	tx.keys = append(tx.keys, keys...)
+}
+
+// doConn executes the command on the given connection,
+// the error reply is returned as error
+func doConn(ctx context.Context, conn *resp3pool.Conn, cmd interface{}, args ...interface{}) (*resp3.Value, error) {
+	resp, err := conn.Do(ctx, cmd, args...)
+	if err != nil {
+		return nil, err
+	}
+	if isErrorResp(resp) {
+		return nil, fmt.Errorf("%s", resp.Err)
+	}
+	return resp, nil
+}
+
+func isErrorResp(resp *resp3.Value) bool {
+	return resp.Type == resp3.TypeSimpleError || resp.Type == resp3.TypeBlobError
+}
@@ -0,0 +1,87 @@
+package resp3
+
+import (
+	"context"
+	"time"
+)
+
+const (
+	// retrackTimeout is the timeout of tracking the watched keys again
+	retrackTimeout = 5 * time.Second
+
+	// retrackDelay is the delay before retrying the failed tracking of the watched keys
+	retrackDelay = time.Second
+)
+
+// Watch watches the changes of the given keys without caching their values.
+//
+// The returned channel receives the key when the key is changed or it might be changed,
+// e.g. the invalidation messages might be lost because the listener was disconnected
+// or the connection which tracked the key was closed.
+// The channel is closed when the ctx is done.
+func (c *Cache) Watch(ctx context.Context, keys ...string) (<-chan string, error) {
+	if len(keys) == 0 {
+		return nil, ErrInvalidArgs
+	}
+
+	// register first, so we don't miss the invalidation messages
+	ch, cancel := c.watches.Watch(ctx, keys)
+	if err := c.trackKeys(ctx, keys); err != nil {
+		cancel()
+		return nil, err
+	}
+	return ch, nil
+}
+
+// trackKeys makes the server track the given keys without reading their values
+func (c *Cache) trackKeys(ctx context.Context, keys []string) error {
+	args := make([]interface{}, 0, len(keys))
+	for _, key := range keys {
+		args = append(args, key)
+	}
+	_, err := c._do(ctx, cmdExists, args...)
+	return err
+}
+
+// notifyWatched notifies the watchers of the key,
+// and makes the server track the key again
+func (c *Cache) notifyWatched(key string) {
+	if c.watches.Notify(key) {
+		go c.retrack([]string{key})
+	}
+}
+
+// notifyAllWatched notifies the watchers of all keys,
+// and makes the server track the keys again
+func (c *Cache) notifyAllWatched() {
+	if keys := c.watches.NotifyAll(); len(keys) > 0 {
+		go c.retrack(keys)
+	}
+}
+
+// retrack makes the server track the watched keys again,
+// the server only sends one invalidation message after the key is tracked.
+//
+// It retries until the keys are tracked, not watched anymore, or the cache is closed.
+func (c *Cache) retrack(keys []string) {
+	for {
+		keys = c.watches.Watched(keys)
+		if len(keys) == 0 {
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), retrackTimeout)
+		err := c.trackKeys(ctx, keys)
+		cancel()
+		if err == nil {
+			return
+		}
+		c.logger.Errorf("failed to track the watched keys: %v", err)
+
+		select {
+		case <-c.closeCh:
+			return
+		case <-time.After(retrackDelay):
+		}
+	}
+}
@@ -1,7 +1,135 @@
 package result
 
+import (
+	"context"
+	"errors"
+)
+
 type StringsResult interface {
 	Bool() (bool, error)
 	String() (string, error)
+	Int64() (int64, error)
+	Float64() (float64, error)
+	Bytes() ([]byte, error)
+
+	// IsNil returns true if the key doesn't hold any value
+	IsNil() bool
+
+	// Scan copies the value to the value pointed by dest
+	Scan(dest interface{}) error
+
 	FromLocalCache() bool
 }
+
+// Decoder decodes the raw value of the key
+type Decoder func(raw []byte) (interface{}, error)
+
+// DecodedResult is the StringsResult which the value has been decoded.
+//
+// The decoded value is stored in the memory cache, so the next reads
+// of the key are not decoding the value again.
+type DecodedResult interface {
+	StringsResult
+
+	// Decoded returns the decoded value
+	Decoded() interface{}
+}
+
+// BatchOpType is type of the batch operation
+type BatchOpType int
+
+const (
+	// BatchGet gets the value of the key
+	BatchGet BatchOpType = iota + 1
+	// BatchSetex sets the value of the key with expiration
+	BatchSetex
+	// BatchDel deletes the keys
+	BatchDel
+)
+
+// BatchOp is an operation queued in the batch.
+//
+// The Res & Err are filled by the execution of the batch
+type BatchOp struct {
+	Type BatchOpType
+	Keys []string // only BatchDel has more than one key
+	Val  interface{}
+	Exp  int
+
+	Res StringsResult
+	Err error
+}
+
+// ErrTxConflict returned when the transaction is aborted
+// because the watched keys were modified
+var ErrTxConflict = errors.New("transaction conflict: watched keys were modified")
+
+// Tx is a transaction on a single connection which watches the keys.
+//
+// The reads are executed immediately on the server, the writes are queued
+// and executed atomically when the transaction func returns without error.
+type Tx interface {
+	// Get gets the value of the key from the server, bypassing the memory cache
+	Get(ctx context.Context, key string) (StringsResult, error)
+
+	// Setex queues the set of the key with the given expiration second
+	Setex(key string, val interface{}, exp int)
+
+	// Del queues the deletion of the keys
+	Del(keys ...string)
+}
+
+// InvalidationReason is the reason of the in memory cache invalidation
+type InvalidationReason int
+
+const (
+	// InvalidationServer means the key was modified,
+	// the invalidation message was sent by the server or other rimcu clients
+	InvalidationServer InvalidationReason = iota + 1
+	// InvalidationFlush means the server flushed the database
+	InvalidationFlush
+	// InvalidationReconnect means the invalidation messages might be lost
+	// because the subscriber was disconnected
+	InvalidationReconnect
+	// InvalidationEviction means the key was evicted because the in memory cache is full
+	InvalidationEviction
+	// InvalidationExpiry means the key was expired in the in memory cache
+	InvalidationExpiry
+)
+
+func (r InvalidationReason) String() string {
+	switch r {
+	case InvalidationServer:
+		return "server"
+	case InvalidationFlush:
+		return "flush"
+	case InvalidationReconnect:
+		return "reconnect"
+	case InvalidationEviction:
+		return "eviction"
+	case InvalidationExpiry:
+		return "expiry"
+	default:
+		return "unknown"
+	}
+}
+
+// InvalidateFunc is called when the key is removed from the in memory cache,
+// the empty key means that any of the keys might be removed
+type InvalidateFunc func(key string, reason InvalidationReason)
+
+// PartitionConfig is the config of the in memory cache partition
+// which hosts the keys with the given prefix
+type PartitionConfig struct {
+	Prefix    string
+	CacheSize int
+}
+
+// CacheStats is the stats of the in memory cache partition
+type CacheStats struct {
+	Size      int // number of the cached keys
+	Capacity  int
+	Hits      uint64
+	Misses    uint64
+	Evictions uint64 // number of the keys evicted because the partition is full
+}
@@ -1,6 +1,8 @@
 package rimcu
 
 import (
+	"time"
+
 	"github.com/iwanbk/rimcu/logger"
 )
 
@@ -16,6 +18,13 @@ const (
 	// with front proxy
 	ProtoResp2ClusterProxy Protocol = "RESP2ClusterProxy"
 
+	// ProtoResp2PubSub represent RESP2 protocol on redis server without
+	// CLIENT TRACKING support, e.g. older redis or the redis compatible servers.
+	//
+	// The invalidation messages are published by rimcu itself, so
+	// the writes which not go through rimcu are not invalidating the cache.
+	ProtoResp2PubSub Protocol = "RESP2PubSub"
+
 	// ProtoResp3 represents RESP3 protocol that supported since Redis 6
 	ProtoResp3 Protocol = "RESP3"
 )
@@ -44,6 +53,56 @@ type Config struct {
 	// ClusterNodes is a list of cluster nodes
 	// only being used by ProtoResp2ClusterProxy protocol.
 	ClusterNodes []string
+
+	// ClusterRefreshInterval is the interval of the cluster topology refresh,
+	// only being used by ProtoResp2ClusterProxy protocol.
+	// Default is 1 minute
+	ClusterRefreshInterval time.Duration
+
+	// ReadFromReplicas routes the cache-miss reads to the replicas,
+	// only being used by ProtoResp2 & ProtoResp2ClusterProxy protocol.
+	ReadFromReplicas bool
+
+	// ReplicaAddrs is a list of replica addresses of the ProtoResp2 protocol.
+	// ProtoResp2ClusterProxy discovers the replicas from the cluster.
+	ReplicaAddrs []string
+
+	// InvalidationChannel is the pub/sub channel of the invalidation messages,
+	// only being used by ProtoResp2PubSub protocol.
+	// Default is `rimcu:invalidate`
+	InvalidationChannel string
+
+	// SlotInvalidation invalidates the cache at cluster hash slot granularity,
+	// all cached keys of the slot are invalidated when one of them is invalidated.
+	// It reduces the memory of the invalidation bookkeeping of the large cache.
+	// Only being used by the RESP2 protocols.
+	SlotInvalidation bool
+
+	// CompressThreshold is the minimum size of the string value to be compressed
+	// before being sent to the server, zero disables the compression.
+	// The compressed values are readable by all rimcu clients, regardless of this config.
+	CompressThreshold int
+
+	// MaxDecompressedSize is the maximum size of the decompressed value,
+	// reading the larger value returns an error. Default is 64MB
+	MaxDecompressedSize int
+
+	// EncryptionKeys is a map of key ID to the AES key (16, 24, or 32 bytes)
+	// to encrypt the string values at rest using AES-GCM, empty map disables the encryption.
+	//
+	// The values are encrypted using the key with EncryptionKeyID.
+	// To rotate the key, add the new key and make it the active one,
+	// the old key is still needed to decrypt the values encrypted by it.
+	//
+	// The values without the encryption header are read as plain values, e.g. the values
+	// written before the encryption is enabled, so it doesn't protect against
+	// the one who could write to the server.
+	EncryptionKeys  map[string][]byte
+	EncryptionKeyID string
+
+	// CacheNegative caches the nonexistent keys as the negative entries,
+	// so reading or checking the existence of them doesn't go to the server.
+	CacheNegative bool
 }
 
 // Rimcu is a redis client which implements client side caching.
@@ -54,6 +113,17 @@ type Rimcu struct {
 	protocol     Protocol
 	clusterNodes []string
 	password     string
+
+	clusterRefreshInterval time.Duration
+	readFromReplicas       bool
+	replicaAddrs           []string
+	invalidationChannel    string
+	slotInvalidation       bool
+	compressThreshold      int
+	maxDecompressedSize    int
+	encryptionKeys         map[string][]byte
+	encryptionKeyID        string
+	cacheNegative          bool
 }
 
 // New creates a new Rimcu redis client
@@ -67,6 +137,17 @@ func New(cfg Config) *Rimcu {
 		protocol:     cfg.Protocol,
 		clusterNodes: cfg.ClusterNodes,
 		password:     cfg.Password,
+
+		clusterRefreshInterval: cfg.ClusterRefreshInterval,
+		readFromReplicas:       cfg.ReadFromReplicas,
+		replicaAddrs:           cfg.ReplicaAddrs,
+		invalidationChannel:    cfg.InvalidationChannel,
+		slotInvalidation:       cfg.SlotInvalidation,
+		compressThreshold:      cfg.CompressThreshold,
+		maxDecompressedSize:    cfg.MaxDecompressedSize,
+		encryptionKeys:         cfg.EncryptionKeys,
+		encryptionKeyID:        cfg.EncryptionKeyID,
+		cacheNegative:          cfg.CacheNegative,
 	}
 }
 
@@ -77,10 +158,22 @@ func (r *Rimcu) NewStringsCache(cfg StringsCacheConfig) (*StringsCache, error) {
 	cfg.protocol = r.protocol
 	cfg.clusterNodes = r.clusterNodes
 	cfg.password = r.password
+	cfg.clusterRefreshInterval = r.clusterRefreshInterval
+	cfg.readFromReplicas = r.readFromReplicas
+	cfg.replicaAddrs = r.replicaAddrs
+	cfg.invalidationChannel = r.invalidationChannel
+	cfg.slotInvalidation = r.slotInvalidation
+	cfg.compressThreshold = r.compressThreshold
+	cfg.maxDecompressedSize = r.maxDecompressedSize
+	cfg.encryptionKeys = r.encryptionKeys
+	cfg.encryptionKeyID = r.encryptionKeyID
+	cfg.cacheNegative = r.cacheNegative
 	return newStringsCache(cfg)
 }
 
 const (
 	defaultCacheSize   = 100000
 	defaultCacheTTLSec = 60 * 20
+
+	defaultTxMaxRetries = 10
 )
@@ -0,0 +1,37 @@
+package rimcu
+
+import (
+	"context"
+
+	"github.com/iwanbk/rimcu/internal/redigo/redis"
+	"github.com/iwanbk/rimcu/result"
+)
+
+// Script is a read only lua script which the result is cached in the memory cache
+type Script struct {
+	script *redis.Script
+}
+
+// NewScript creates a new Script from the given lua source.
+//
+// The script must only read the keys given to the EvalRO and return a scalar value
+func NewScript(src string) *Script {
+	return &Script{
+		script: redis.NewScript(-1, src),
+	}
+}
+
+// Hash returns the SHA1 hash of the script
+func (s *Script) Hash() string {
+	return s.script.Hash()
+}
+
+// EvalRO evaluates the read only script with the given keys and args.
+//
+// The keys are all of the keys read by the script. The result is cached in the memory cache
+// keyed by the script hash, the keys, and the args, and it is invalidated when one of the keys
+// is invalidated. The script is evaluated using EVALSHA, and EVAL if the script is not loaded yet.
+func (sc *StringsCache) EvalRO(ctx context.Context, script *Script, keys []string, exp int,
+	args ...interface{}) (result.StringsResult, error) {
+	return sc.engine.EvalRO(ctx, script.script, sc.keys(keys), exp, args...)
+}
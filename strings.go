@@ -3,7 +3,9 @@ package rimcu
 import (
 	"context"
 	"fmt"
+	"time"
 
+	"github.com/iwanbk/rimcu/internal/redigo/redis"
 	"github.com/iwanbk/rimcu/logger"
 	"github.com/iwanbk/rimcu/resp2"
 	"github.com/iwanbk/rimcu/resp3"
@@ -12,24 +14,93 @@ import (
 
 // StringsCache is Rimcu client for the strings redis data type
 type StringsCache struct {
-	engine stringsCacheEngine
+	engine       stringsCacheEngine
+	txMaxRetries int
+	keyPrefix    string
+
+	// partitionPrefix is the prefix of the partition relative to the keyPrefix of the engine,
+	// empty if this cache is not a partition
+	partitionPrefix string
 }
 
 type stringsCacheEngine interface {
 	Setex(ctx context.Context, key string, val interface{}, exp int) error
 	Get(ctx context.Context, key string, expSecond int) (result.StringsResult, error)
-	Del(ctx context.Context, key string) error
+	GetDecoded(ctx context.Context, key string, expSecond int, decode result.Decoder) (result.DecodedResult, error)
+	Del(ctx context.Context, keys ...string) error
+	Unlink(ctx context.Context, keys ...string) error
+	Exists(ctx context.Context, keys ...string) (int64, error)
+
+	Incr(ctx context.Context, key string) (int64, error)
+	IncrBy(ctx context.Context, key string, delta int64) (int64, error)
+	Decr(ctx context.Context, key string) (int64, error)
+	DecrBy(ctx context.Context, key string, delta int64) (int64, error)
+	IncrByFloat(ctx context.Context, key string, delta float64) (float64, error)
+
+	SetNX(ctx context.Context, key string, val interface{}, exp int) (bool, error)
+	SetXX(ctx context.Context, key string, val interface{}, exp int) (bool, error)
+	GetSet(ctx context.Context, key string, val interface{}, exp int) (result.StringsResult, error)
+	CompareAndSwap(ctx context.Context, key string, oldVal, newVal interface{}, exp int) (bool, error)
+
+	Expire(ctx context.Context, key string, exp int) (bool, error)
+	PExpire(ctx context.Context, key string, expMillisecond int64) (bool, error)
+	Persist(ctx context.Context, key string) (bool, error)
+	TTL(ctx context.Context, key string) (int64, error)
+	GetEx(ctx context.Context, key string, exp int) (result.StringsResult, error)
+
+	Append(ctx context.Context, key, val string) (int64, error)
+	SetRange(ctx context.Context, key string, offset int64, val string) (int64, error)
+	GetRange(ctx context.Context, key string, start, end int64) (string, error)
+	StrLen(ctx context.Context, key string) (int64, error)
+
+	ExecBatch(ctx context.Context, ops []*result.BatchOp) error
+	Tx(ctx context.Context, watchKeys []string, fn func(tx result.Tx) error) error
+	EvalRO(ctx context.Context, script *redis.Script, keys []string, exp int, args ...interface{}) (result.StringsResult, error)
+	Compute(ctx context.Context, name string, deps []string, exp int,
+		fn func(ctx context.Context) (interface{}, error)) (interface{}, error)
+
+	SetexTags(ctx context.Context, key string, val interface{}, exp int, tags ...string) error
+	InvalidateTag(ctx context.Context, tag string) error
+
+	OnInvalidate(fn result.InvalidateFunc)
+	Watch(ctx context.Context, keys ...string) (<-chan string, error)
+	Stats(prefix string) result.CacheStats
 }
 
 // StringsCacheConfig is the configuration of the StringsCache
 type StringsCacheConfig struct {
 	CacheSize    int
 	CacheTTLSec  int
+	TxMaxRetries int // max retries of the conflicted transaction, default is 10
+
+	// KeyPrefix is prepended to all keys of this cache, so multiple services
+	// could share one redis server. The invalidated keys are reported without it.
+	//
+	// The broadcasting tracking subscribers of the ProtoResp2ClusterProxy protocol
+	// and the SlotInvalidation only receive the invalidation of the keys with this prefix.
+	KeyPrefix string
+
+	// Partitions splits the in memory cache by the key prefix, so the keys of
+	// one partition never evict the keys of the other partitions.
+	// The keys without matching partition are in the default partition which size is the CacheSize.
+	Partitions []PartitionConfig
+
 	protocol     Protocol
 	serverAddr   string
 	logger       logger.Logger
 	clusterNodes []string // TODO: make it only 1 listener
 	password     string
+
+	clusterRefreshInterval time.Duration
+	readFromReplicas       bool
+	replicaAddrs           []string
+	invalidationChannel    string
+	slotInvalidation       bool
+	compressThreshold      int
+	maxDecompressedSize    int
+	encryptionKeys         map[string][]byte
+	encryptionKeyID        string
+	cacheNegative          bool
 }
 
 func newStringsCache(cfg StringsCacheConfig) (*StringsCache, error) {
@@ -43,17 +114,36 @@ func newStringsCache(cfg StringsCacheConfig) (*StringsCache, error) {
 	if cfg.CacheTTLSec <= 0 {
 		cfg.CacheTTLSec = defaultCacheTTLSec
 	}
+	if cfg.TxMaxRetries <= 0 {
+		cfg.TxMaxRetries = defaultTxMaxRetries
+	}
+	partitions, err := enginePartitions(cfg.KeyPrefix, cfg.CacheSize, cfg.Partitions)
+	if err != nil {
+		return nil, err
+	}
 
 	switch cfg.protocol {
 	case ProtoResp3:
 		engine = resp3.New(resp3.Config{
 			ServerAddr: cfg.serverAddr,
+			CacheSize:  cfg.CacheSize,
 			Logger:     cfg.logger,
+
+			CompressThreshold:   cfg.compressThreshold,
+			MaxDecompressedSize: cfg.maxDecompressedSize,
+			EncryptionKeys:      cfg.encryptionKeys,
+			EncryptionKeyID:     cfg.encryptionKeyID,
+			CacheNegative:       cfg.cacheNegative,
+			KeyPrefix:           cfg.KeyPrefix,
+			Partitions:          partitions,
 		})
-	case ProtoResp2, ProtoResp2ClusterProxy:
+	case ProtoResp2, ProtoResp2ClusterProxy, ProtoResp2PubSub:
 		var mode = resp2.ModeSingle
-		if cfg.protocol == ProtoResp2ClusterProxy {
+		switch cfg.protocol {
+		case ProtoResp2ClusterProxy:
 			mode = resp2.ModeClusterProxy
+		case ProtoResp2PubSub:
+			mode = resp2.ModePubSub
 		}
 		engine, err = resp2.NewStringsCache(resp2.StringsCacheConfig{
 			CacheSize:    cfg.CacheSize,
@@ -62,6 +152,19 @@ func newStringsCache(cfg StringsCacheConfig) (*StringsCache, error) {
 			ClusterNodes: cfg.clusterNodes,
 			Password:     cfg.password,
 			Mode:         mode,
+
+			ClusterRefreshInterval: cfg.clusterRefreshInterval,
+			ReadFromReplicas:       cfg.readFromReplicas,
+			ReplicaAddrs:           cfg.replicaAddrs,
+			InvalidationChannel:    cfg.invalidationChannel,
+			SlotInvalidation:       cfg.slotInvalidation,
+			CompressThreshold:      cfg.compressThreshold,
+			MaxDecompressedSize:    cfg.maxDecompressedSize,
+			EncryptionKeys:         cfg.encryptionKeys,
+			EncryptionKeyID:        cfg.encryptionKeyID,
+			CacheNegative:          cfg.cacheNegative,
+			KeyPrefix:              cfg.KeyPrefix,
+			Partitions:             partitions,
 		})
 	default:
 		err = fmt.Errorf("unknown protocol: %s", cfg.protocol)
@@ -72,7 +175,9 @@ func newStringsCache(cfg StringsCacheConfig) (*StringsCache, error) {
 	}
 
 	return &StringsCache{
-		engine: engine,
+		engine:       engine,
+		txMaxRetries: cfg.TxMaxRetries,
+		keyPrefix:    cfg.KeyPrefix,
 	}, nil
 }
 
@@ -80,7 +185,7 @@ func newStringsCache(cfg StringsCacheConfig) (*StringsCache, error) {
 //
 // Calling this func will invalidate inmem cache of this key's slot in all nodes
 func (sc *StringsCache) Setex(ctx context.Context, key string, val interface{}, exp int) error {
-	return sc.engine.Setex(ctx, key, val, exp)
+	return sc.engine.Setex(ctx, sc.key(key), val, exp)
 }
 
 // Get gets the value of key.
@@ -88,10 +193,159 @@ func (sc *StringsCache) Setex(ctx context.Context, key string, val interface{},
 // It gets from the redis server only if the value not exists in memory cache,
 // it then put the value from server in the in memcache with the given expiration
 func (sc *StringsCache) Get(ctx context.Context, key string, expSecond int) (result.StringsResult, error) {
-	return sc.engine.Get(ctx, key, expSecond)
+	return sc.engine.Get(ctx, sc.key(key), expSecond)
+}
+
+// Del deletes the keys in both memory cache and redis server
+func (sc *StringsCache) Del(ctx context.Context, keys ...string) error {
+	return sc.engine.Del(ctx, sc.keys(keys)...)
 }
 
-// Del deletes the key in both memory cache and redis server
-func (sc *StringsCache) Del(ctx context.Context, key string) error {
-	return sc.engine.Del(ctx, key)
+// Unlink deletes the keys in both memory cache and redis server,
+// the memory of the values is reclaimed by the server in the background
+func (sc *StringsCache) Unlink(ctx context.Context, keys ...string) error {
+	return sc.engine.Unlink(ctx, sc.keys(keys)...)
+}
+
+// Exists returns the number of the given keys which exist.
+//
+// The keys which exist in the memory cache, including the cached nonexistent keys,
+// are not sent to the redis server
+func (sc *StringsCache) Exists(ctx context.Context, keys ...string) (int64, error) {
+	return sc.engine.Exists(ctx, sc.keys(keys)...)
+}
+
+// Incr increments the number stored at key by one and returns the new value.
+//
+// Calling this func will invalidate inmem cache of the key in all nodes.
+//
+// It is not supported if the compression or encryption is enabled.
+func (sc *StringsCache) Incr(ctx context.Context, key string) (int64, error) {
+	return sc.engine.Incr(ctx, sc.key(key))
+}
+
+// IncrBy increments the number stored at key by delta and returns the new value.
+//
+// It is not supported if the compression or encryption is enabled.
+func (sc *StringsCache) IncrBy(ctx context.Context, key string, delta int64) (int64, error) {
+	return sc.engine.IncrBy(ctx, sc.key(key), delta)
+}
+
+// Decr decrements the number stored at key by one and returns the new value.
+//
+// It is not supported if the compression or encryption is enabled.
+func (sc *StringsCache) Decr(ctx context.Context, key string) (int64, error) {
+	return sc.engine.Decr(ctx, sc.key(key))
+}
+
+// DecrBy decrements the number stored at key by delta and returns the new value.
+//
+// It is not supported if the compression or encryption is enabled.
+func (sc *StringsCache) DecrBy(ctx context.Context, key string, delta int64) (int64, error) {
+	return sc.engine.DecrBy(ctx, sc.key(key), delta)
+}
+
+// IncrByFloat increments the floating point number stored at key by delta
+// and returns the new value.
+//
+// It is not supported if the compression or encryption is enabled.
+func (sc *StringsCache) IncrByFloat(ctx context.Context, key string, delta float64) (float64, error) {
+	return sc.engine.IncrByFloat(ctx, sc.key(key), delta)
+}
+
+// SetNX sets the key to hold the value with the given expiration second,
+// only if the key doesn't exist.
+//
+// It returns true if the key was set
+func (sc *StringsCache) SetNX(ctx context.Context, key string, val interface{}, exp int) (bool, error) {
+	return sc.engine.SetNX(ctx, sc.key(key), val, exp)
+}
+
+// SetXX sets the key to hold the value with the given expiration second,
+// only if the key already exists.
+//
+// It returns true if the key was set
+func (sc *StringsCache) SetXX(ctx context.Context, key string, val interface{}, exp int) (bool, error) {
+	return sc.engine.SetXX(ctx, sc.key(key), val, exp)
+}
+
+// GetSet sets the key to hold the value with the given expiration second
+// and returns the old value, the result is nil if the key didn't exist.
+func (sc *StringsCache) GetSet(ctx context.Context, key string, val interface{}, exp int) (result.StringsResult, error) {
+	return sc.engine.GetSet(ctx, sc.key(key), val, exp)
+}
+
+// CompareAndSwap sets the key to hold the new value with the given expiration second,
+// only if the current value equals to the old value.
+//
+// It returns true if the value was swapped
+func (sc *StringsCache) CompareAndSwap(ctx context.Context, key string, oldVal, newVal interface{}, exp int) (bool, error) {
+	return sc.engine.CompareAndSwap(ctx, sc.key(key), oldVal, newVal, exp)
+}
+
+// Expire sets the expiration of the key in second.
+//
+// The in memory cache of the key is invalidated, so it never outlives the new expiration.
+// It returns false if the key doesn't exist
+func (sc *StringsCache) Expire(ctx context.Context, key string, exp int) (bool, error) {
+	return sc.engine.Expire(ctx, sc.key(key), exp)
+}
+
+// PExpire sets the expiration of the key in millisecond.
+//
+// The in memory cache of the key is invalidated, so it never outlives the new expiration.
+// It returns false if the key doesn't exist
+func (sc *StringsCache) PExpire(ctx context.Context, key string, expMillisecond int64) (bool, error) {
+	return sc.engine.PExpire(ctx, sc.key(key), expMillisecond)
+}
+
+// Persist removes the expiration of the key.
+//
+// It returns false if the key doesn't exist or doesn't have an expiration
+func (sc *StringsCache) Persist(ctx context.Context, key string) (bool, error) {
+	return sc.engine.Persist(ctx, sc.key(key))
+}
+
+// TTL returns the remaining time to live of the key in second.
+//
+// It returns -1 if the key has no expiration, and -2 if the key doesn't exist
+func (sc *StringsCache) TTL(ctx context.Context, key string) (int64, error) {
+	return sc.engine.TTL(ctx, sc.key(key))
+}
+
+// GetEx gets the value of the key and sets it's expiration in second.
+//
+// The value is read from the redis server and not cached in the memory cache.
+func (sc *StringsCache) GetEx(ctx context.Context, key string, exp int) (result.StringsResult, error) {
+	return sc.engine.GetEx(ctx, sc.key(key), exp)
+}
+
+// Append appends the value to the end of the key and returns the new length.
+//
+// It is not supported if the compression or encryption is enabled.
+func (sc *StringsCache) Append(ctx context.Context, key, val string) (int64, error) {
+	return sc.engine.Append(ctx, sc.key(key), val)
+}
+
+// SetRange overwrites part of the value of the key starting at the offset,
+// and returns the new length.
+//
+// It is not supported if the compression or encryption is enabled.
+func (sc *StringsCache) SetRange(ctx context.Context, key string, offset int64, val string) (int64, error) {
+	return sc.engine.SetRange(ctx, sc.key(key), offset, val)
+}
+
+// GetRange returns the substring of the value of the key between the start and end offsets,
+// both inclusive and could be negative to count from the end of the value.
+//
+// It is served from the in memory cache if the key exists there.
+func (sc *StringsCache) GetRange(ctx context.Context, key string, start, end int64) (string, error) {
+	return sc.engine.GetRange(ctx, sc.key(key), start, end)
+}
+
+// StrLen returns the length of the value of the key, zero if the key doesn't exist.
+//
+// It is served from the in memory cache if the key exists there.
+func (sc *StringsCache) StrLen(ctx context.Context, key string) (int64, error) {
+	return sc.engine.StrLen(ctx, sc.key(key))
 }
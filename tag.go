@@ -0,0 +1,21 @@
+package rimcu
+
+import (
+	"context"
+)
+
+// SetexTags sets the key to hold the string value with the given expiration second,
+// and attaches the tags to the key.
+//
+// The tag index is stored in the redis sets with `rimcu:tag:` prefix under the KeyPrefix,
+// the tags of a Partition are under the partition prefix.
+// The index is shared by all nodes and only cleaned by the InvalidateTag.
+func (sc *StringsCache) SetexTags(ctx context.Context, key string, val interface{}, exp int, tags ...string) error {
+	return sc.engine.SetexTags(ctx, sc.key(key), val, exp, sc.tags(tags)...)
+}
+
+// InvalidateTag deletes all keys which have the given tag in the redis server,
+// and invalidates their inmem cache in all nodes.
+func (sc *StringsCache) InvalidateTag(ctx context.Context, tag string) error {
+	return sc.engine.InvalidateTag(ctx, sc.tag(tag))
+}
@@ -0,0 +1,39 @@
+package rimcu
+
+import (
+	"context"
+
+	"github.com/iwanbk/rimcu/result"
+)
+
+// ErrTxConflict returned when the transaction is still conflicted
+// after all of the retries
+var ErrTxConflict = result.ErrTxConflict
+
+// Tx executes the transaction func on a single connection which watches the given keys.
+//
+// The reads of the transaction go to the server, and the writes are executed atomically
+// using MULTI/EXEC when the func returns without error.
+// The in memory cache of the written keys are invalidated only after the EXEC succeed.
+//
+// If the watched keys were modified before the EXEC, the func is called again
+// up to TxMaxRetries times, so it must not have side effects other than the transaction.
+func (sc *StringsCache) Tx(ctx context.Context, fn func(tx result.Tx) error, watchKeys ...string) error {
+	if sc.keyPrefix != "" {
+		txFn := fn
+		fn = func(tx result.Tx) error {
+			return txFn(prefixTx{sc: sc, tx: tx})
+		}
+	}
+	watchKeys = sc.keys(watchKeys)
+
+	for i := 0; ; i++ {
+		err := sc.engine.Tx(ctx, watchKeys, fn)
+		if err != result.ErrTxConflict || i >= sc.txMaxRetries {
+			return err
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+	}
+}
@@ -0,0 +1,75 @@
+package rimcu
+
+import (
+	"context"
+	"fmt"
+)
+
+// TypedCache is a StringsCache which stores values of type T,
+// encoded using the given Codec.
+//
+// The in memory cache stores the decoded value, so the cache hit doesn't
+// need to decode the value again. The returned value is shared between
+// the callers, it must not be modified if T is a pointer, slice, or map.
+type TypedCache[T any] struct {
+	sc    *StringsCache
+	codec Codec
+}
+
+// NewTypedCache creates a new TypedCache on top of the given StringsCache
+func NewTypedCache[T any](sc *StringsCache, codec Codec) *TypedCache[T] {
+	return &TypedCache[T]{
+		sc:    sc,
+		codec: codec,
+	}
+}
+
+// Setex sets the key to hold the encoded value with the given expiration second.
+func (tc *TypedCache[T]) Setex(ctx context.Context, key string, val T, exp int) error {
+	data, err := tc.codec.Marshal(val)
+	if err != nil {
+		return err
+	}
+	return tc.sc.Setex(ctx, key, data, exp)
+}
+
+// Get gets the decoded value of key.
+//
+// It gets from the redis server only if the value not exists in memory cache,
+// it then put the decoded value in the in memcache with the given expiration
+func (tc *TypedCache[T]) Get(ctx context.Context, key string, expSecond int) (T, error) {
+	var zero T
+
+	res, err := tc.sc.engine.GetDecoded(ctx, tc.sc.key(key), expSecond, tc.decode)
+	if err != nil {
+		return zero, err
+	}
+
+	if val, ok := res.Decoded().(T); ok {
+		return val, nil
+	}
+
+	// the value was decoded by another TypedCache with different type
+	str, err := res.String()
+	if err != nil {
+		return zero, err
+	}
+	decoded, err := tc.decode([]byte(str))
+	if err != nil {
+		return zero, err
+	}
+	return decoded.(T), nil
+}
+
+// Del deletes the key in both memory cache and redis server
+func (tc *TypedCache[T]) Del(ctx context.Context, key string) error {
+	return tc.sc.Del(ctx, key)
+}
+
+func (tc *TypedCache[T]) decode(raw []byte) (interface{}, error) {
+	var val T
+	if err := tc.codec.Unmarshal(raw, &val); err != nil {
+		return nil, fmt.Errorf("failed to decode value: %w", err)
+	}
+	return val, nil
+}
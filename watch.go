@@ -0,0 +1,33 @@
+package rimcu
+
+import (
+	"context"
+)
+
+// Watch watches the changes of the given keys without caching their values.
+//
+// The returned channel receives the key when the key is changed, or when it might be changed
+// because the invalidation messages might be lost, e.g. the subscriber was reconnected.
+// The pending notifications of the same key are coalesced.
+// The channel is closed when the ctx is done, the caller must cancel the ctx to release the resources.
+func (sc *StringsCache) Watch(ctx context.Context, keys ...string) (<-chan string, error) {
+	ch, err := sc.engine.Watch(ctx, sc.keys(keys)...)
+	if err != nil || sc.keyPrefix == "" {
+		return ch, err
+	}
+
+	// strip the prefix of the notified keys
+	stripped := make(chan string)
+	go func() {
+		defer close(stripped)
+		for key := range ch {
+			key, _ = sc.stripKey(key)
+			select {
+			case stripped <- key:
+			case <-ctx.Done():
+				// the engine closes the ch
+			}
+		}
+	}()
+	return stripped, nil
+}